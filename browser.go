@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// openURL hands link to $BROWSER when it is set (a colon separated list, "%s"
+// marks where the url goes), otherwise to the platform's default opener. Post
+// links come from the feed, so anything but a plain http(s) url is refused
+// rather than handed to whatever the opener would run for file:, javascript:
+// or a custom scheme
+func openURL(link string) error {
+
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("not opening %q, only http and https links are opened", link)
+	}
+	link = parsed.String()
+
+	if browsers := os.Getenv("BROWSER"); browsers != "" {
+		var lastErr error
+		for _, browser := range strings.Split(browsers, ":") {
+			parts := strings.Fields(browser)
+			if len(parts) == 0 {
+				continue
+			}
+
+			args := parts[1:]
+			replaced := false
+			for i, v := range args {
+				if strings.Contains(v, "%s") {
+					args[i] = strings.ReplaceAll(v, "%s", link)
+					replaced = true
+				}
+			}
+			if !replaced {
+				args = append(args, link)
+			}
+
+			lastErr = startDetached(parts[0], args...)
+			if lastErr == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("could not start $BROWSER: %w", lastErr)
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return startDetached("open", link)
+	case "windows":
+		return startDetached("rundll32", "url.dll,FileProtocolHandler", link)
+	}
+	return startDetached("xdg-open", link)
+}
+
+func startDetached(name string, args ...string) error {
+
+	cmd := exec.Command(name, args...)
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	go cmd.Wait()
+	return nil
+}
+
+// showInPager pipes text through $PAGER (less by default), or just prints it
+// when stdout is not a terminal
+func showInPager(text string) error {
+
+	if !stdoutIsTerminal() {
+		_, err := fmt.Println(text)
+		return err
+	}
+
+	pager := strings.Fields(os.Getenv("PAGER"))
+	if len(pager) == 0 {
+		pager = []string{"less"}
+		if runtime.GOOS == "windows" {
+			pager = []string{"more"}
+		}
+	}
+
+	cmd := exec.Command(pager[0], pager[1:]...)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// let less pass colours through and exit straight away on short posts
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	return cmd.Run()
+}
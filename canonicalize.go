@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Feeds and posts stored before urls were canonicalized keep the url they
+// came with until canonicalize-urls rewrites them. Feeds that turn out to be
+// the same feed are merged into one: follows, tags, posts and read/starred
+// marks move over to the feed that stays, except where it already has them
+
+type feedMerge struct {
+	Into   database.Feed
+	URL    string
+	Merged []database.Feed
+}
+
+// planFeedMerges groups feeds by canonical url. The feed that stays is the one
+// already stored under it, or else the oldest
+func planFeedMerges(feeds []database.Feed) []feedMerge {
+
+	groups := map[string][]database.Feed{}
+	for _, v := range feeds {
+		if !v.Url.Valid {
+			continue
+		}
+		canonical := canonicalURL(v.Url.String, nil)
+		groups[canonical] = append(groups[canonical], v)
+	}
+
+	var plan []feedMerge
+	for canonical, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			if (group[i].Url.String == canonical) != (group[j].Url.String == canonical) {
+				return group[i].Url.String == canonical
+			}
+			return group[i].CreatedAt.Before(group[j].CreatedAt)
+		})
+		if len(group) == 1 && group[0].Url.String == canonical {
+			continue
+		}
+		plan = append(plan, feedMerge{Into: group[0], URL: canonical, Merged: group[1:]})
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].URL < plan[j].URL })
+	return plan
+}
+
+// mergeFeed moves everything from one feed onto another and deletes it, q is
+// expected to be inside a transaction
+func mergeFeed(q *database.Queries, into, from uuid.UUID) error {
+
+	ctx := context.Background()
+	err := q.MovePostStates(ctx, database.MovePostStatesParams{ToFeedID: into, FromFeedID: from})
+	if err != nil {
+		return err
+	}
+	err = q.MovePosts(ctx, database.MovePostsParams{ToFeedID: into, FromFeedID: from})
+	if err != nil {
+		return err
+	}
+	err = q.MoveFeedFollows(ctx, database.MoveFeedFollowsParams{ToFeedID: into, FromFeedID: from})
+	if err != nil {
+		return err
+	}
+	err = q.MoveFeedTags(ctx, database.MoveFeedTagsParams{ToFeedID: into, FromFeedID: from})
+	if err != nil {
+		return err
+	}
+	err = q.MoveFeedRetention(ctx, database.MoveFeedRetentionParams{ToFeedID: into, FromFeedID: from})
+	if err != nil {
+		return err
+	}
+	err = q.MoveFilterRules(ctx, database.MoveFilterRulesParams{ToFeedID: into, FromFeedID: from})
+	if err != nil {
+		return err
+	}
+	err = q.MovePrunedPosts(ctx, database.MovePrunedPostsParams{ToFeedID: into, FromFeedID: from})
+	if err != nil {
+		return err
+	}
+	return q.DeleteFeed(ctx, from)
+}
+
+func handlerCanonicalizeURLs(s *state, cmd command) error {
+
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return err
+	}
+	posts, err := s.db.GetPostURLs(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// relative post links are left as they are: the base agg would have
+	// resolved them against is gone, stored feed and site urls are canonical
+	// and may have lost a trailing slash, so resolving now could only guess
+	plan := planFeedMerges(feeds)
+	var postURLs database.SetPostURLsParams
+	for _, v := range posts {
+		canonical := canonicalURL(v.Url, nil)
+		if canonical != v.Url {
+			postURLs.Ids = append(postURLs.Ids, v.ID)
+			postURLs.Urls = append(postURLs.Urls, canonical)
+		}
+	}
+
+	verb := "rewrote"
+	if cmd.flagBool("dry-run") {
+		verb = "would rewrite"
+	}
+	for _, v := range plan {
+		if v.Into.Url.String != v.URL {
+			fmt.Printf("%s %s -> %s\n", verb, v.Into.Url.String, v.URL)
+		}
+		for _, merged := range v.Merged {
+			fmt.Printf("%s %s -> %s, merging %s into %s\n", verb, merged.Url.String, v.URL, merged.Name, v.Into.Name)
+		}
+	}
+	fmt.Printf("%s %d post urls\n", verb, len(postURLs.Ids))
+	if cmd.flagBool("dry-run") {
+		return nil
+	}
+
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	for _, v := range plan {
+		for _, merged := range v.Merged {
+			err = mergeFeed(qtx, v.Into.ID, merged.ID)
+			if err != nil {
+				return err
+			}
+		}
+		if v.Into.Url.String != v.URL {
+			err = qtx.SetFeedURL(context.Background(), database.SetFeedURLParams{
+				ID: v.Into.ID,
+				Url: sql.NullString{String: v.URL, Valid: true},
+				UpdatedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(postURLs.Ids) > 0 {
+		err = qtx.SetPostURLs(context.Background(), postURLs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -3,8 +3,15 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/colfarl/gator/internal/database"
@@ -16,88 +23,672 @@ import (
 type command struct {
 	Name		string
 	Args		[]string
+	Output		string
+	Flags		*flag.FlagSet
+	Spec		*commandSpec
+}
+
+func (cmd command) flagValue(name string) any {
+	f := cmd.Flags.Lookup(name)
+	if f == nil {
+		panic(fmt.Sprintf("command %s has no flag: %s", cmd.Name, name))
+	}
+	return f.Value.(flag.Getter).Get()
+}
+
+func (cmd command) flagString(name string) string {
+	return cmd.flagValue(name).(string)
+}
+
+func (cmd command) flagInt(name string) int {
+	return cmd.flagValue(name).(int)
+}
+
+func (cmd command) flagBool(name string) bool {
+	return cmd.flagValue(name).(bool)
+}
+
+func (cmd command) usageError() error {
+	return fmt.Errorf("USAGE: %s", cmd.Spec.usage())
+}
+
+// splitGlobalFlags pulls the options every command accepts (--output) out of
+// the argument list so they can appear before or after the command name
+func splitGlobalFlags(args []string) ([]string, string, error) {
+
+	output := "text"
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--output" || arg == "-output":
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("--output needs a value: %s", strings.Join(outputFormats, "|"))
+			}
+			output = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--output=") || strings.HasPrefix(arg, "-output="):
+			_, output, _ = strings.Cut(arg, "=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	if !validOutputFormat(output) {
+		return nil, "", fmt.Errorf("unknown output format: %s (want %s)", output, strings.Join(outputFormats, "|"))
+	}
+	return rest, output, nil
 }
 
 func argsToCommand(args []string) (command, error) {
 
 	if len(args) < 2 {
-		return command{}, fmt.Errorf("USAGE: <program> <command> [args]")
+		return command{}, fmt.Errorf("USAGE: <program> [--output text|json|jsonl|csv|tsv] <command> [args]; run '<program> help' for a list of commands")
+	}
+
+	if args[1] == completeCommand {
+		return command{
+					Name: completeCommand,
+					Args: args[2:],
+					Output: "text",
+				}, nil
+	}
+
+	rest, output, err := splitGlobalFlags(args[1:])
+	if err != nil {
+		return command{}, err
+	}
+	if len(rest) < 1 {
+		return command{}, fmt.Errorf("USAGE: <program> [--output text|json|jsonl|csv|tsv] <command> [args]; run '<program> help' for a list of commands")
 	}
 	
-	cmdName := args[1]
+	cmdName := rest[0]
 	
-	if len(args) == 2 {
+	if len(rest) == 1 {
 		return command{
 					Name: cmdName,
 					Args: nil,
+					Output: output,
 				}, nil
 	}
 	return command{
 				Name: cmdName,
-				Args: args[2:], 
+				Args: rest[1:], 
+				Output: output,
 			}, nil
 }
 
+// ============ Command Specs ================
+
+type argSpec struct {
+	Name		string
+	Usage		string
+	Optional	bool
+	Variadic	bool
+	Complete	completer
+}
+
+// flagSpec describes one flag, the type of Default decides the flag type
+// (string, int, bool or time.Duration)
+type flagSpec struct {
+	Name		string
+	Value		string
+	Default		any
+	Usage		string
+	Complete	completer
+}
+
+// commands marked RawArgs get their arguments untouched, without flag parsing
+// or count checks. Hidden ones are left out of help and completion
+type commandSpec struct {
+	Name		string
+	Description	string
+	Args		[]argSpec
+	Flags		[]flagSpec
+	Handler		func(*state, command) error
+	Hidden		bool
+	RawArgs		bool
+}
+
+func flagName(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
+func (f flagSpec) usage() string {
+	if _, ok := f.Default.(bool); ok {
+		return flagName(f.Name)
+	}
+	return fmt.Sprintf("%s <%s>", flagName(f.Name), f.Value)
+}
+
+func (a argSpec) usage() string {
+	result := "<" + a.Name + ">"
+	if a.Variadic {
+		result += "..."
+	}
+	if a.Optional {
+		result = "[" + result + "]"
+	}
+	return result
+}
+
+func (spec *commandSpec) usage() string {
+	parts := []string{spec.Name}
+	for _, a := range spec.Args {
+		parts = append(parts, a.usage())
+	}
+	for _, f := range spec.Flags {
+		parts = append(parts, "["+f.usage()+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (spec *commandSpec) flagSet() *flag.FlagSet {
+
+	fs := flag.NewFlagSet(spec.Name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	for _, f := range spec.Flags {
+		switch v := f.Default.(type) {
+		case string:
+			fs.String(f.Name, v, f.Usage)
+		case int:
+			fs.Int(f.Name, v, f.Usage)
+		case bool:
+			fs.Bool(f.Name, v, f.Usage)
+		case time.Duration:
+			fs.Duration(f.Name, v, f.Usage)
+		default:
+			panic(fmt.Sprintf("unsupported flag type %T for %s %s", v, spec.Name, f.Name))
+		}
+	}
+	return fs
+}
+
+// parse lets flags and positional arguments be mixed in any order, the
+// standard flag package stops at the first positional one
+func (spec *commandSpec) parse(args []string) (*flag.FlagSet, []string, error) {
+
+	fs := spec.flagSet()
+	var positional []string
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		consumed := len(args) - fs.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, fs.Args()...)
+			break
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	min, max := 0, 0
+	for _, a := range spec.Args {
+		if !a.Optional {
+			min++
+		}
+		if a.Variadic {
+			max = -1
+		} else if max >= 0 {
+			max++
+		}
+	}
+	if len(positional) < min || (max >= 0 && len(positional) > max) {
+		return nil, nil, fmt.Errorf("USAGE: %s", spec.usage())
+	}
+
+	return fs, positional, nil
+}
+
+func (spec *commandSpec) printHelp() {
+
+	fmt.Printf("USAGE: %s\n\n", spec.usage())
+	fmt.Println(spec.Description)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if len(spec.Args) > 0 {
+		fmt.Fprintln(w, "\nArguments:")
+		for _, a := range spec.Args {
+			fmt.Fprintf(w, "  %s\t%s\n", a.usage(), a.Usage)
+		}
+	}
+	if len(spec.Flags) > 0 {
+		fmt.Fprintln(w, "\nFlags:")
+		for _, f := range spec.Flags {
+			usage := f.Usage
+			if v, ok := f.Default.(string); !ok || v != "" {
+				if _, isBool := f.Default.(bool); !isBool {
+					usage += fmt.Sprintf(" (default %v)", f.Default)
+				}
+			}
+			fmt.Fprintf(w, "  %s\t%s\n", f.usage(), usage)
+		}
+	}
+	w.Flush()
+}
+
 // ============ Commands Struct ================
 
 type commands struct {
-	SupportedCommands			map[string]func(*state, command) error
+	SupportedCommands			map[string]*commandSpec
 }
 
-func newCommands() commands {
+func newCommands() *commands {
 	var c commands
 	c.initialize()
-	return c
+	return &c
+}
+
+// lookup finds the spec for cmd, joining the first argument onto the name
+// for commands with subcommands like "export opml"
+func (c *commands) lookup(cmd command) (*commandSpec, command, error) {
+
+	if len(cmd.Args) > 0 {
+		if spec, ok := c.SupportedCommands[cmd.Name+" "+cmd.Args[0]]; ok {
+			cmd.Name = spec.Name
+			cmd.Args = cmd.Args[1:]
+			return spec, cmd, nil
+		}
+	}
+
+	if spec, ok := c.SupportedCommands[cmd.Name]; ok {
+		return spec, cmd, nil
+	}
+
+	subcommands := c.subcommands(cmd.Name)
+	if len(subcommands) > 0 {
+		return nil, cmd, fmt.Errorf("USAGE: %s <%s> [args]", cmd.Name, strings.Join(subcommands, "|"))
+	}
+	return nil, cmd, fmt.Errorf("command: %s does not exist; run 'gator help' for a list of commands", cmd.Name)
+}
+
+func (c *commands) subcommands(group string) []string {
+	var result []string
+	for name := range c.SupportedCommands {
+		if sub, ok := strings.CutPrefix(name, group+" "); ok {
+			result = append(result, sub)
+		}
+	}
+	sort.Strings(result)
+	return result
 }
 
 func (c *commands) run(s *state, cmd command) error {
-	cmdName := cmd.Name
-	cmdFunction, ok := c.SupportedCommands[cmdName]
-	if !ok {
-		return fmt.Errorf("command: %s does not exist", cmdName)
-	 }
 
-	err := cmdFunction(s, cmd) 
+	spec, cmd, err := c.lookup(cmd)
+	if err != nil {
+		return err
+	}
+
+	if spec.RawArgs {
+		cmd.Spec = spec
+		return spec.Handler(s, cmd)
+	}
+
+	fs, args, err := spec.parse(cmd.Args)
+	if errors.Is(err, flag.ErrHelp) {
+		spec.printHelp()
+		return nil
+	}
 	if err != nil {
+		if !strings.HasPrefix(err.Error(), "USAGE:") {
+			return fmt.Errorf("%w\nUSAGE: %s", err, spec.usage())
+		}
 		return err
 	}
+
+	cmd.Args = args
+	cmd.Flags = fs
+	cmd.Spec = spec
+	return spec.Handler(s, cmd)
+}
+
+func (c *commands) register(spec commandSpec) {
+	c.SupportedCommands[spec.Name] = &spec
+}
+
+func (c *commands) names() []string {
+	var result []string
+	for name := range c.SupportedCommands {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (c *commands) handlerHelp(s *state, cmd command) error {
+
+	if len(cmd.Args) > 0 {
+		spec, _, err := c.lookup(command{Name: cmd.Args[0], Args: cmd.Args[1:]})
+		if err != nil {
+			return err
+		}
+		spec.printHelp()
+		return nil
+	}
+
+	fmt.Println("gator - follow RSS/Atom feeds from the terminal")
+	fmt.Println()
+	fmt.Println("USAGE: gator [--output text|json|jsonl|csv|tsv] <command> [args]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, name := range c.names() {
+		if c.SupportedCommands[name].Hidden {
+			continue
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", name, c.SupportedCommands[name].Description)
+	}
+	w.Flush()
+	fmt.Println()
+	fmt.Println("Run 'gator help <command>' or 'gator <command> --help' for details.")
 	return nil
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
-	c.SupportedCommands[name] = f	
+var feedExportFlags = []flagSpec{
+	{Name: "format", Value: "atom|rss", Default: "atom", Usage: "feed format to write", Complete: completeWords("atom", "rss")},
+	{Name: "limit", Value: "n", Default: 50, Usage: "number of posts to include"},
+	{Name: "tag", Value: "tag", Default: "", Usage: "only include posts from feeds with this tag", Complete: completeTags},
 }
 
 func (c *commands) initialize() {
-	c.SupportedCommands = make(map[string]func(*state, command) error)
-	c.register("login", handlerLogin)
-	c.register("register", handlerRegister)
-	c.register("reset", handlerReset)
-	c.register("users", handlerUsers)
-	c.register("agg", handlerAgg)
-	c.register("feeds", handlerFeeds)
-	c.register("browse", middlewareLoggedIn(handlerBrowse))
-	c.register("addfeed", middlewareLoggedIn(handlerAddFeed))
-	c.register("follow", middlewareLoggedIn(handlerFollow))
-	c.register("following", middlewareLoggedIn(handlerFollowing))
-	c.register("unfollow", middlewareLoggedIn(handlerUnfollow))
+	c.SupportedCommands = make(map[string]*commandSpec)
+	c.register(commandSpec{
+		Name: "help",
+		Description: "Show all commands, or the details of one command",
+		Args: []argSpec{{Name: "command", Usage: "command to describe", Optional: true, Variadic: true, Complete: c.completeCommandNames}},
+		Handler: c.handlerHelp,
+	})
+	c.register(commandSpec{
+		Name: "completion",
+		Description: "Print a shell completion script",
+		Args: []argSpec{{Name: "shell", Usage: "bash, zsh or fish", Complete: completeWords("bash", "zsh", "fish")}},
+		Handler: handlerCompletion,
+	})
+	c.register(commandSpec{
+		Name: completeCommand,
+		Description: "List completions for the words typed so far, used by the completion scripts",
+		Handler: c.handlerComplete,
+		Hidden: true,
+		RawArgs: true,
+	})
+	c.register(commandSpec{
+		Name: "login",
+		Description: "Log in as a user, asking for their password",
+		Args: []argSpec{{Name: "user-name", Usage: "an existing user", Complete: completeUsers}},
+		Handler: handlerLogin,
+	})
+	c.register(commandSpec{
+		Name: "register",
+		Description: "Create a user and log in as them",
+		Args: []argSpec{{Name: "user-name", Usage: "name for the new user"}},
+		Handler: handlerRegister,
+	})
+	c.register(commandSpec{
+		Name: "logout",
+		Description: "Log out and revoke the session token saved by login",
+		Handler: handlerLogout,
+	})
+	c.register(commandSpec{
+		Name: "passwd",
+		Description: "Change your password, or as an admin set someone else's",
+		Flags: []flagSpec{
+			{Name: "user", Value: "user", Default: "", Usage: "admin only: set this user's password instead, or the admin's first one", Complete: completeUsers},
+		},
+		Handler: handlerPasswd,
+	})
+	c.register(commandSpec{
+		Name: "reset",
+		Description: "Delete every user, and with them every feed, follow and post",
+		Flags: []flagSpec{
+			{Name: "yes", Default: false, Usage: "don't ask for confirmation"},
+		},
+		Handler: middlewareAdmin(handlerReset),
+	})
+	c.register(commandSpec{
+		Name: "user delete",
+		Description: "Delete one user, keeping the feeds they added for everyone else",
+		Args: []argSpec{{Name: "user-name", Usage: "an existing user", Complete: completeUsers}},
+		Flags: []flagSpec{
+			{Name: "reassign-to", Value: "user", Default: "", Usage: "hand the feeds they added to this user", Complete: completeUsers},
+			{Name: "yes", Default: false, Usage: "don't ask for confirmation"},
+		},
+		Handler: handlerUserDelete,
+	})
+	c.register(commandSpec{
+		Name: "users",
+		Description: "List all users",
+		Handler: handlerUsers,
+	})
+	c.register(commandSpec{
+		Name: "agg",
+		Description: "Fetch the stalest followed feed on an interval, forever",
+		Args: []argSpec{{Name: "time-between-reqs", Usage: "how long to wait between fetches: 1h, 1m, 1s..."}},
+		Flags: append([]flagSpec{
+			{Name: "export-feed", Value: "file", Default: "", Usage: "rewrite this file with the current user's timeline after each fetch"},
+			{Name: "gc", Default: false, Usage: "delete feeds nobody follows after each fetch"},
+			{Name: "prune", Default: false, Usage: "apply post retention after each fetch"},
+		}, feedExportFlags...),
+		Handler: handlerAgg,
+	})
+	c.register(commandSpec{
+		Name: "gc",
+		Description: "Delete feeds nobody follows any more, with their posts",
+		Flags: []flagSpec{
+			{Name: "dry-run", Default: false, Usage: "only list what would be deleted"},
+		},
+		Handler: handlerGC,
+	})
+	c.register(commandSpec{
+		Name: "canonicalize-urls",
+		Description: "Rewrite feed and post urls stored before canonicalization, merging feeds that turn out the same",
+		Flags: []flagSpec{
+			{Name: "dry-run", Default: false, Usage: "only list what would change"},
+		},
+		Handler: handlerCanonicalizeURLs,
+	})
+	c.register(commandSpec{
+		Name: "prune",
+		Description: "Delete read, unstarred posts that retention no longer keeps",
+		Flags: []flagSpec{
+			{Name: "dry-run", Default: false, Usage: "only count what would be deleted"},
+		},
+		Handler: handlerPrune,
+	})
+	c.register(commandSpec{
+		Name: "retention",
+		Description: "Show the default post retention and per-feed overrides",
+		Handler: handlerRetention,
+	})
+	c.register(commandSpec{
+		Name: "retention set",
+		Description: "Set how many posts, or how many days of posts, to keep",
+		Flags: []flagSpec{
+			{Name: "feed", Value: "url", Default: "", Usage: "override the default for this feed only", Complete: completeFeedURLs},
+			{Name: "keep-posts", Value: "n", Default: -1, Usage: "keep the newest n posts per feed, 0 for no limit"},
+			{Name: "keep-days", Value: "days", Default: -1, Usage: "keep posts younger than this many days, 0 for no limit"},
+		},
+		Handler: middlewareLoggedIn(handlerRetentionSet),
+	})
+	c.register(commandSpec{
+		Name: "retention clear",
+		Description: "Go back to keeping everything, or drop a feed's override",
+		Flags: []flagSpec{
+			{Name: "feed", Value: "url", Default: "", Usage: "only drop this feed's override", Complete: completeFeedURLs},
+		},
+		Handler: middlewareLoggedIn(handlerRetentionClear),
+	})
+	c.register(commandSpec{
+		Name: "filter add",
+		Description: "Hide, highlight, star or mark read posts whose title or text matches",
+		Flags: []flagSpec{
+			{Name: "feed", Value: "url", Default: "", Usage: "only match posts from this feed", Complete: completeFollowedURLs},
+			{Name: "title-regex", Value: "regex", Default: "", Usage: "match titles against this regular expression"},
+			{Name: "keyword", Value: "word", Default: "", Usage: "match posts mentioning this in the title or description, ignoring case"},
+			{Name: "action", Value: "hide|highlight|star|mark-read", Default: "", Usage: "what to do with matching posts", Complete: completeWords(filterActions...)},
+		},
+		Handler: middlewareLoggedIn(handlerFilterAdd),
+	})
+	c.register(commandSpec{
+		Name: "filter list",
+		Description: "List your filter rules",
+		Handler: middlewareLoggedIn(handlerFilterList),
+	})
+	c.register(commandSpec{
+		Name: "filter remove",
+		Description: "Remove one of your filter rules",
+		Args: []argSpec{{Name: "filter", Usage: "the start of its id, as shown by filter list", Complete: completeFilterIDs}},
+		Handler: middlewareLoggedIn(handlerFilterRemove),
+	})
+	c.register(commandSpec{
+		Name: "feeds",
+		Description: "List all feeds",
+		Handler: handlerFeeds,
+	})
+	c.register(commandSpec{
+		Name: "browse",
+		Description: "Show the newest posts from the feeds you follow",
+		Args: []argSpec{{Name: "limit", Usage: "number of posts to show (default 2)", Optional: true}},
+		Flags: []flagSpec{
+			{Name: "raw", Default: false, Usage: "print descriptions as the original HTML"},
+			{Name: "updated", Default: false, Usage: "only posts that changed since you read them"},
+			{Name: "show-hidden", Default: false, Usage: "include posts your filters hide"},
+		},
+		Handler: middlewareLoggedIn(handlerBrowse),
+	})
+	c.register(commandSpec{
+		Name: "open",
+		Description: "Open a post in your browser and mark it read",
+		Args: []argSpec{{Name: "post", Usage: "id shown by browse, or the post url"}},
+		Handler: middlewareLoggedIn(handlerOpen),
+	})
+	c.register(commandSpec{
+		Name: "show",
+		Description: "Read a whole post in your pager",
+		Args: []argSpec{{Name: "post", Usage: "id shown by browse, or the post url"}},
+		Handler: middlewareLoggedIn(handlerShow),
+	})
+	c.register(commandSpec{
+		Name: "tui",
+		Description: "Read your feeds in a full screen terminal reader",
+		Handler: middlewareLoggedIn(handlerTUI),
+	})
+	c.register(commandSpec{
+		Name: "addfeed",
+		Description: "Add a feed and follow it",
+		Args: []argSpec{
+			{Name: "feed-name", Usage: "name shown for the feed"},
+			{Name: "feed-url", Usage: "url of the RSS/Atom document"},
+		},
+		Handler: middlewareLoggedIn(handlerAddFeed),
+	})
+	c.register(commandSpec{
+		Name: "follow",
+		Description: "Follow a feed that is already in gator",
+		Args: []argSpec{{Name: "url", Usage: "url of the feed", Complete: completeFeedURLs}},
+		Handler: middlewareLoggedIn(handlerFollow),
+	})
+	c.register(commandSpec{
+		Name: "following",
+		Description: "List the feeds you follow",
+		Handler: middlewareLoggedIn(handlerFollowing),
+	})
+	c.register(commandSpec{
+		Name: "unfollow",
+		Description: "Stop following a feed",
+		Args: []argSpec{{Name: "url", Usage: "url of the feed", Complete: completeFollowedURLs}},
+		Handler: middlewareLoggedIn(handlerUnfollow),
+	})
+	c.register(commandSpec{
+		Name: "rename-feed",
+		Description: "Show a followed feed under your own name",
+		Args: []argSpec{
+			{Name: "url", Usage: "url of the feed", Complete: completeFollowedURLs},
+			{Name: "display-name", Usage: "name to show, leave off to go back to the original", Optional: true},
+		},
+		Handler: middlewareLoggedIn(handlerRenameFeed),
+	})
+	c.register(commandSpec{
+		Name: "import opml",
+		Description: "Add and follow every feed in an OPML file, folders become tags",
+		Args: []argSpec{{Name: "file", Usage: "OPML document to read"}},
+		Handler: middlewareLoggedIn(handlerImportOPML),
+	})
+	c.register(commandSpec{
+		Name: "export opml",
+		Description: "Write the feeds you follow as an OPML 2.0 document",
+		Flags: []flagSpec{
+			{Name: "tag", Value: "tag", Default: "", Usage: "only export feeds with this tag", Complete: completeTags},
+			{Name: "o", Value: "file", Default: "", Usage: "write to a file instead of stdout"},
+		},
+		Handler: middlewareLoggedIn(handlerExportOPML),
+	})
+	c.register(commandSpec{
+		Name: "export feed",
+		Description: "Render your timeline as an Atom or RSS feed",
+		Flags: append(append([]flagSpec{}, feedExportFlags...),
+			flagSpec{Name: "o", Value: "file", Default: "", Usage: "write to a file instead of stdout"},
+		),
+		Handler: middlewareLoggedIn(handlerExportFeed),
+	})
+	c.register(commandSpec{
+		Name: "token create",
+		Description: "Create an API token for the current user",
+		Flags: []flagSpec{
+			{Name: "name", Value: "name", Default: "default", Usage: "label to tell tokens apart"},
+			{Name: "fever", Default: false, Usage: "also let Fever clients log in with it"},
+		},
+		Handler: middlewareLoggedIn(handlerTokenCreate),
+	})
+	c.register(commandSpec{
+		Name: "token list",
+		Description: "List the current user's API tokens",
+		Handler: middlewareLoggedIn(handlerTokenList),
+	})
+	c.register(commandSpec{
+		Name: "token revoke",
+		Description: "Revoke one of the current user's API tokens",
+		Args: []argSpec{{Name: "token", Usage: "token name or the start of its id", Complete: completeTokenNames}},
+		Handler: middlewareLoggedIn(handlerTokenRevoke),
+	})
+	c.register(commandSpec{
+		Name: "serve",
+		Description: "Serve the web reader and JSON API over HTTP, clients authenticate with a token",
+		Flags: []flagSpec{
+			{Name: "listen", Value: "addr", Default: "127.0.0.1:8080", Usage: "address to listen on"},
+			{Name: "no-web", Default: false, Usage: "only serve the JSON API, not the web reader"},
+		},
+		Handler: handlerServe,
+	})
 }
 
 // ============================== Command Handlers ==============================  
 
 func handlerLogin(s *state, cmd command) error {
 
-	if len(cmd.Args) != 1 || cmd.Args == nil {
-		return fmt.Errorf("USAGE: login <user-name>")
-	}
-	
+	// unknown users, and users from before passwords who can't prove who they
+	// are until an admin gives them one, get the same errWrongPassword as a
+	// wrong password, so nobody can probe for user names
 	newUser := cmd.Args[0]
-	_, err := s.db.GetUser(context.Background(), sql.NullString{String: newUser, Valid: true})
-	if  err != nil {
-		return fmt.Errorf("user does not exist")
+	password, err := readPassword("Password: ")
+	if err != nil {
+		return err
+	}
+	user, err := logInWithPassword(s, newUser, password)
+	if err != nil {
+		return err
 	}
 
-	err = s.updateUser(newUser)
+	err = startSession(s, user)
 	if err != nil {
 		return err
 	}
@@ -106,18 +697,113 @@ func handlerLogin(s *state, cmd command) error {
 	return nil
 }
 
-func handlerRegister(s * state, cmd command) error {
-	
-	if len(cmd.Args) != 1 || cmd.Args == nil {
-		return fmt.Errorf("USAGE: register <user-name>")
+func handlerLogout(s *state, cmd command) error {
+
+	if s.CurrentState.CurrentUserToken == "" {
+		return fmt.Errorf("not logged in")
 	}
+
+	err := endSession(s)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("logged out")
+	return nil
+}
+
+func handlerPasswd(s *state, cmd command) error {
+
+	user, err := currentUser(s)
+	if err != nil {
+		return setFirstAdminPassword(s, cmd.flagString("user"), err)
+	}
+
+	if name := cmd.flagString("user"); name != "" && name != user.Name.String {
+		if !user.IsAdmin {
+			return fmt.Errorf("only an admin can set another user's password")
+		}
+		target, err := s.db.GetUser(context.Background(), sql.NullString{String: name, Valid: true})
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("user does not exist")
+		}
+		if err != nil {
+			return err
+		}
+
+		err = setPassword(s, target)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("password set for %s\n", name)
+		return nil
+	}
+
+	if user.PasswordHash.Valid {
+		password, err := readPassword("Current password: ")
+		if err != nil {
+			return err
+		}
+		err = checkPassword(user, password)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setPassword(s, user)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("password changed for %s\n", user.Name.String)
+	return nil
+}
+
+// setFirstAdminPassword lets the admin from before passwords existed set one.
+// Nobody can log in as them to do it, so it is the only password that can be
+// set without a session, and only once
+func setFirstAdminPassword(s *state, name string, notLoggedIn error) error {
+
+	if name == "" {
+		return notLoggedIn
+	}
+	admin, err := s.db.GetUser(context.Background(), sql.NullString{String: name, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		return notLoggedIn
+	}
+	if err != nil {
+		return err
+	}
+	if !admin.IsAdmin || admin.PasswordHash.Valid {
+		return notLoggedIn
+	}
+
+	err = setPassword(s, admin)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("password set for %s, log in with: gator login %s\n", name, name)
+	return nil
+}
+
+func handlerRegister(s * state, cmd command) error {
 	
 	newName := cmd.Args[0]
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+	passwordHash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
 	newUser := database.CreateUserParams{
 		ID: uuid.New(),
 		CreatedAt:  time.Now(),
 		UpdatedAt: time.Now(),
 		Name: sql.NullString{String: newName, Valid: true},
+		PasswordHash: passwordHash,
 	}
 
 	user, err := s.db.CreateUser(context.Background(), newUser)
@@ -125,105 +811,291 @@ func handlerRegister(s * state, cmd command) error {
 		return err;
 	}
 
-	s.updateUser(newName)
+	err = startSession(s, user)
+	if err != nil {
+		return err
+	}
 	fmt.Printf("new user %s created\n", newName)
+	if user.IsAdmin {
+		fmt.Println("this is the first user, so they are the admin")
+	}
 	printUser(user)
 	return nil
 }
 
-func handlerReset(s * state, cmd command) error {
+func handlerReset(s * state, cmd command, user database.User) error {
 
-	if len(cmd.Args) != 0 {
-		return fmt.Errorf("USAGE: reset")
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return err
 	}
-	
-	err := s.db.DeleteUsers(context.Background())
+
+	question := fmt.Sprintf("Delete all %d users and every feed, follow and post?", len(users))
+	err = confirm(question, cmd.flagBool("yes"))
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	deleted, err := qtx.DeleteUsers(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// feeds outlive their creators, so they have to go separately
+	_, err = qtx.DeleteFeeds(context.Background())
 	if err != nil {
 		return err
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	err = s.updateUser("", "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("deleted %d users\n", deleted)
 	return nil
 }
 
-func handlerUsers(s * state, cmd command) error {	
+// handlerUserDelete removes one user. Feeds they added are shared with their
+// followers, so they are handed to --reassign-to or kept without an owner
+// rather than deleted along with the user
+func handlerUserDelete(s * state, cmd command) error {
 
-	if len(cmd.Args) != 0 {
-		return fmt.Errorf("USAGE: users")
+	// an admin can delete anyone, everybody else has to know the password of
+	// the user going away, and is told nothing about users they can't prove
+	name := cmd.Args[0]
+	var user database.User
+	current, err := currentUser(s)
+	if err == nil && current.IsAdmin {
+		user, err = s.db.GetUser(context.Background(), sql.NullString{String: name, Valid: true})
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no user named %q", name)
+		}
+	} else {
+		var password string
+		password, err = readPassword(fmt.Sprintf("Password for %s: ", name))
+		if err != nil {
+			return err
+		}
+		user, err = logInWithPassword(s, name, password)
 	}
-	
-	names, err := s.db.GetUsers(context.Background())
 	if err != nil {
 		return err
 	}
 
-	for _, v := range names {
-		name := v.String
-		fmt.Printf(" * %s", name)
-		if name == s.CurrentState.CurrentUserName {
-			fmt.Print(" (current)")
+	// nobody else can become the admin, so the admin stays
+	if user.IsAdmin {
+		return fmt.Errorf("%s is the admin and can't be deleted", name)
+	}
+
+	newOwner := uuid.NullUUID{}
+	newOwnerName := cmd.flagString("reassign-to")
+	if newOwnerName != "" {
+		if newOwnerName == name {
+			return fmt.Errorf("can't reassign feeds to the user being deleted")
+		}
+		owner, err := s.db.GetUser(context.Background(), sql.NullString{String: newOwnerName, Valid: true})
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no user named %q to reassign feeds to", newOwnerName)
+		}
+		if err != nil {
+			return err
+		}
+		newOwner = uuid.NullUUID{UUID: owner.ID, Valid: true}
+	}
+
+	counts, err := s.db.GetUserDataCounts(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	question := fmt.Sprintf("Delete user %s with %d follows, %d tags, %d read/starred marks and %d tokens?",
+		name, counts.Follows, counts.Tags, counts.PostStates, counts.Tokens)
+	err = confirm(question, cmd.flagBool("yes"))
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	reassigned, err := qtx.ReassignFeeds(context.Background(), database.ReassignFeedsParams{
+		NewUserID: newOwner,
+		OldUserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = qtx.DeleteUser(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// their session token went with them
+	if s.CurrentState.CurrentUserName == name {
+		err = s.updateUser("", "")
+		if err != nil {
+			return err
 		}
-		fmt.Println()
 	}
 
+	fmt.Printf("deleted user %s: %d follows, %d tags, %d read/starred marks, %d tokens\n",
+		name, counts.Follows, counts.Tags, counts.PostStates, counts.Tokens)
+	if reassigned > 0 && newOwner.Valid {
+		fmt.Printf("%d feeds they added now belong to %s\n", reassigned, newOwnerName)
+	} else if reassigned > 0 {
+		fmt.Printf("%d feeds they added are kept without an owner\n", reassigned)
+	}
 	return nil
 }
 
-func handlerAgg(s * state, cmd command) error {	
+func handlerUsers(s * state, cmd command) error {	
 
-	if len(cmd.Args) != 1 {
-		return fmt.Errorf("USAGE: agg <time-between-reqs: 1h, 1m, 1s...>")
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return err
 	}
-	
+
+	// listing users works logged out too, there's just nobody to highlight
+	current, _ := currentUser(s)
+
+	var records []userRecord
+	for _, v := range users {
+		records = append(records, newUserRecord(v, current.Name.String))
+	}
+
+	return printListing(cmd.Output, records, func(records []userRecord) {
+		for _, v := range records {
+			fmt.Printf(" * %s", stringValue(v.Name))
+			if v.Admin {
+				fmt.Print(" (admin)")
+			}
+			if v.Current {
+				fmt.Print(" (current)")
+			}
+			fmt.Println()
+		}
+	})
+}
+
+func handlerAgg(s * state, cmd command) error {	
+
 	timeBetweenRequests, err := time.ParseDuration(cmd.Args[0])
 	if err != nil {
 		return err
 	}
 
+	gc := cmd.flagBool("gc")
+	prune := cmd.flagBool("prune")
+	exportPath := cmd.flagString("export-feed")
+	format := cmd.flagString("format")
+	tag := cmd.flagString("tag")
+	limit := cmd.flagInt("limit")
+	if limit < 1 {
+		return cmd.usageError()
+	}
+
+	var user database.User
+	if exportPath != "" {
+		user, err = currentUser(s)
+		if err != nil {
+			return err
+		}
+	}
+
 	ticker := time.NewTicker(timeBetweenRequests)
 	for ; ; <-ticker.C {
 		scrapeFeeds(s)
+
+		if gc {
+			orphans, err := collectOrphanedFeeds(s, false)
+			if err != nil {
+				fmt.Println("gc failed:", err)
+			} else if len(orphans) > 0 {
+				printOrphanedFeeds(orphans, "collected")
+			}
+		}
+
+		if prune {
+			pruned, err := prunePosts(s, false)
+			if err != nil {
+				fmt.Println("prune failed:", err)
+			}
+			if len(pruned) > 0 {
+				printPrunedFeeds(pruned, "pruned")
+			}
+		}
+
+		if exportPath != "" {
+			err = writeTimelineFile(s, exportPath, user, format, tag, int32(limit))
+			if err != nil {
+				fmt.Println("export failed:", err)
+			}
+		}
 	}
 
 }
 
 func handlerFeeds(s *state, cmd command) error {
 	
-	if len(cmd.Args) != 0 {
-		return fmt.Errorf("USAGE: addfeed <feed-name> <feed-url>")
-	}
-		
 	allFeeds, err := s.db.GetFeeds(context.Background())
 	if err != nil {
 		return err
 	}
 
+	var records []feedRecord
 	for _, v := range allFeeds {
-		creatorName, err := s.db.GetUserNameByID(context.Background(), v.UserID.UUID)
-		if err != nil {
-			return err
+		var creatorName sql.NullString
+		if v.UserID.Valid {
+			creatorName, err = s.db.GetUserNameByID(context.Background(), v.UserID.UUID)
+			if err != nil {
+				return err
+			}
 		}
-		
-		fmt.Println()
-		fmt.Println("Feed Name:", v.Name)
-		fmt.Println("URL:", v.Url.String)
-		fmt.Println("Creator Name:", creatorName.String)
-		fmt.Println()
+		records = append(records, newFeedRecord(v, creatorName))
 	}
-	return nil
+
+	return printListing(cmd.Output, records, func(records []feedRecord) {
+		for _, v := range records {
+			fmt.Println()
+			fmt.Println("Feed Name:", v.Name)
+			fmt.Println("URL:", stringValue(v.Url))
+			fmt.Println("Creator Name:", stringValue(v.CreatorName))
+			fmt.Println()
+		}
+	})
 }
 
 // ============================== "LOGGED IN FUNCTIONS" ============================== 
 func handlerFollow(s *state, cmd command, user database.User) error {
 
-	if len(cmd.Args) != 1 {
-		return fmt.Errorf("USAGE: follow <url>")
-	}
-	
-		
-	feedID, err := s.db.GetFeedIdByURL(context.Background(), sql.NullString{String: cmd.Args[0], Valid: cmd.Args[0] != ""})
+	feed, err := lookupFeed(context.Background(), s.db, cmd.Args[0])
 	if err != nil {
 		return err
 	}
+	feedID := feed.ID
 
 	params := database.CreateFeedFollowParams{
 		ID: uuid.New(),
@@ -244,88 +1116,233 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 
 func handlerFollowing(s *state, cmd command, user database.User) error {
 
-	if len(cmd.Args) != 0 {
-		return fmt.Errorf("USAGE: following")
-	}
-
 	allFollowing, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
 	if err != nil {
 		return err
 	}
-	
-	fmt.Println("You are Currently Following:")
+
+	var records []followRecord
 	for _, v := range allFollowing {
-		fmt.Printf("	- '%s'\n", v.FeedName)
+		records = append(records, newFollowRecord(v))
 	}
 
-	return nil
+	return printListing(cmd.Output, records, func(records []followRecord) {
+		fmt.Println("You are Currently Following:")
+		for _, v := range records {
+			if v.DisplayName != nil {
+				fmt.Printf("	- '%s' (%s)\n", v.FeedTitle, v.FeedName)
+				continue
+			}
+			fmt.Printf("	- '%s'\n", v.FeedTitle)
+		}
+	})
 }
 
 func handlerAddFeed(s * state, cmd command, user database.User) error {	
 
-	if len(cmd.Args) != 2 {
-		return fmt.Errorf("USAGE: addfeed <feed-name> <feed-url>")
+	added, err := addFeed(s, user, cmd.Args[0], cmd.Args[1])
+	if err != nil {
+		return err
 	}
-	
-	params := database.CreateFeedParams{
-		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name: cmd.Args[0],
-		Url: sql.NullString{String: cmd.Args[1], Valid: cmd.Args[1] != ""},
-		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+
+	switch {
+	case added.Created:
+		fmt.Println("Successfully added feed:", added.Feed.Name)
+	case added.Followed:
+		fmt.Printf("feed already exists as %s, now following it\n", added.Feed.Name)
+	default:
+		fmt.Printf("feed already exists as %s and you follow it\n", added.Feed.Name)
 	}
+	return nil
+}
 
-	inserted, err := s.db.CreateFeed(context.Background(), params)
+func handlerUnfollow(s *state, cmd command, user database.User) error {
+	
+	feed, err := lookupFeed(context.Background(), s.db, cmd.Args[0])
 	if err != nil {
 		return err
 	}
+	feedID := feed.ID
 	
-	paramsFollowing := database.CreateFeedFollowParams{
-		ID: uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		FeedID: inserted.ID,
-		UserID:	user.ID,
+	params := database.DeleteFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
 	}
-	
-	_, err = s.db.CreateFeedFollow(context.Background(), paramsFollowing)
+
+	err = s.db.DeleteFeedFollow(context.Background(), params)	
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func handlerOpen(s *state, cmd command, user database.User) error {
+
+	post, err := findPost(s, user, cmd.Args[0])
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Successfully added feed:", inserted.Name)
+	err = openURL(post.Url)
+	if err != nil {
+		return err
+	}
+
+	params := database.SetPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+
+	err = s.db.SetPostRead(context.Background(), params)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Opened:", stripControl(post.Url))
 	return nil
 }
 
-func handlerUnfollow(s *state, cmd command, user database.User) error {
-	
-	if len(cmd.Args) != 1 {
-		return fmt.Errorf("USAGE: unfollow <url>")
+func handlerShow(s *state, cmd command, user database.User) error {
+
+	post, err := findPost(s, user, cmd.Args[0])
+	if err != nil {
+		return err
 	}
 
-	feedID, err := s.db.GetFeedIdByURL(context.Background(), sql.NullString{String: cmd.Args[0], Valid: cmd.Args[0] != ""})
+	ansi := stdoutIsTerminal()
+	title := stripControl(post.Title)
+	if ansi {
+		title = ansiBold + title + ansiReset
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n", title)
+	fmt.Fprintf(&b, "%s · %s\n", stripControl(post.FeedTitle), post.PublishedAt.Format("Mon Jan 2 2006 15:04"))
+	fmt.Fprintf(&b, "%s\n", stripControl(post.Url))
+	if post.Url != "" {
+		others, err := s.db.GetDuplicatePostFeeds(context.Background(), database.GetDuplicatePostFeedsParams{
+			UserID: user.ID,
+			Url: post.Url,
+			ID: post.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if len(others) > 0 {
+			fmt.Fprintf(&b, "also in: %s\n", stripControl(strings.Join(others, ", ")))
+		}
+	}
+
+	revisions, err := s.db.GetPostRevisions(context.Background(), post.ID)
 	if err != nil {
 		return err
 	}
-	
-	params := database.DeleteFeedFollowParams{
+	if len(revisions) > 0 {
+		fmt.Fprintf(&b, "updated %s, %d earlier versions\n", post.UpdatedAt.Format("Mon Jan 2 2006 15:04"), len(revisions))
+		if revisions[len(revisions)-1].Title != post.Title {
+			fmt.Fprintf(&b, "first published as: %s\n", stripControl(revisions[len(revisions)-1].Title))
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(renderHTML(post.Description.String, min(terminalWidth(), 100), ansi))
+
+	return showInPager(b.String())
+}
+
+func handlerRenameFeed(s *state, cmd command, user database.User) error {
+
+	feed, err := lookupFeed(context.Background(), s.db, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	feedID := feed.ID
+
+	// leaving the name off clears the override and falls back to feeds.name
+	var displayName sql.NullString
+	if len(cmd.Args) == 2 {
+		displayName = sql.NullString{String: cmd.Args[1], Valid: cmd.Args[1] != ""}
+	}
+
+	params := database.SetFeedFollowDisplayNameParams{
 		UserID: user.ID,
 		FeedID: feedID,
+		DisplayName: displayName,
 	}
 
-	err = s.db.DeleteFeedFollow(context.Background(), params)	
+	updated, err := s.db.SetFeedFollowDisplayName(context.Background(), params)
 	if err != nil {
 		return err
 	}
+	if updated == 0 {
+		return fmt.Errorf("you are not following: %s", cmd.Args[0])
+	}
+
+	if displayName.Valid {
+		fmt.Printf("feed %s is now shown as: %s\n", cmd.Args[0], displayName.String)
+	} else {
+		fmt.Printf("feed %s is now shown by its original name\n", cmd.Args[0])
+	}
 	return nil
 }
 
-func handlerBrowse(s *state, cmd command, user database.User) error {
-	
-	if len(cmd.Args) > 1 {
-		return fmt.Errorf("USAGE: browse [limit]")
+func handlerImportOPML(s *state, cmd command, user database.User) error {
+
+	doc, err := readOPML(cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	result := importOPML(s, user, doc)
+	fmt.Printf("Created: %d, Existing: %d, Failed: %d\n", result.Created, result.Existing, result.Failed)
+	if result.Failed > 0 {
+		return fmt.Errorf("%d feeds could not be imported", result.Failed)
+	}
+	return nil
+}
+
+func handlerExportOPML(s *state, cmd command, user database.User) error {
+
+	doc, err := buildOPML(s, user, cmd.flagString("tag"))
+	if err != nil {
+		return err
+	}
+
+	out := cmd.flagString("o")
+	if out == "" {
+		return writeOPML(os.Stdout, doc)
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
+
+	err = writeOPML(file, doc)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func handlerExportFeed(s *state, cmd command, user database.User) error {
+
+	format := cmd.flagString("format")
+	tag := cmd.flagString("tag")
+	limit := cmd.flagInt("limit")
+	if limit < 1 {
+		return cmd.usageError()
+	}
+
+	out := cmd.flagString("o")
+	if out == "" {
+		return writeTimelineFeed(s, os.Stdout, user, format, tag, int32(limit))
+	}
+	return writeTimelineFile(s, out, user, format, tag, int32(limit))
+}
+
+func handlerBrowse(s *state, cmd command, user database.User) error {
 	
 	var limit int32
 	if len(cmd.Args) == 1 {
@@ -340,20 +1357,29 @@ func handlerBrowse(s *state, cmd command, user database.User) error {
 	
 	params := database.GetPostsForUserParams{
 		UserID: user.ID, 
+		ShowHidden: cmd.flagBool("show-hidden"),
 		Limit: limit,
 	}
+	if cmd.flagBool("updated") {
+		params.Updated = sql.NullBool{Bool: true, Valid: true}
+	}
 
 	posts, err := s.db.GetPostsForUser(context.Background(), params)
 	if err != nil {
 		return err
 	}
 
+	var records []postRecord
 	for _, post := range posts {
-		fmt.Println()
-		prettyPost(post)
-		fmt.Println()
+		records = append(records, newPostRecord(post))
 	}
-	
-	return nil
+
+	return printListing(cmd.Output, records, func(records []postRecord) {
+		for _, post := range records {
+			fmt.Println()
+			prettyPost(post, cmd.flagBool("raw"))
+			fmt.Println()
+		}
+	})
 }
 
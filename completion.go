@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const completeCommand = "__complete"
+
+// completer returns every value that fits an argument, the caller filters by
+// what has been typed so far
+type completer func(s *state) []string
+
+func completeWords(words ...string) completer {
+	return func(s *state) []string {
+		return words
+	}
+}
+
+func completeUsers(s *state) []string {
+	users, err := s.db.GetUsers(context.Background())
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, v := range users {
+		if v.Name.Valid {
+			result = append(result, v.Name.String)
+		}
+	}
+	return result
+}
+
+func completeTokenNames(s *state) []string {
+	user, err := currentUser(s)
+	if err != nil {
+		return nil
+	}
+	tokens, err := s.db.GetAPITokensForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, v := range tokens {
+		result = append(result, v.Name)
+	}
+	return result
+}
+
+func completeFilterIDs(s *state) []string {
+	user, err := currentUser(s)
+	if err != nil {
+		return nil
+	}
+	rules, err := s.db.GetFilterRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, v := range rules {
+		result = append(result, shortID(v.ID))
+	}
+	return result
+}
+
+func completeFeedURLs(s *state) []string {
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, v := range feeds {
+		if v.Url.Valid {
+			result = append(result, v.Url.String)
+		}
+	}
+	return result
+}
+
+func completeFollowedURLs(s *state) []string {
+	user, err := currentUser(s)
+	if err != nil {
+		return nil
+	}
+
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, v := range follows {
+		if v.FeedUrl.Valid {
+			result = append(result, v.FeedUrl.String)
+		}
+	}
+	return result
+}
+
+func completeTags(s *state) []string {
+	user, err := currentUser(s)
+	if err != nil {
+		return nil
+	}
+
+	tags, err := s.db.GetFeedTagsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, v := range tags {
+		names = append(names, v.Name)
+	}
+	return uniqueTags(names)
+}
+
+// ======== Completion Engine ========
+
+func (c *commands) topLevelNames() []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, name := range c.names() {
+		if c.SupportedCommands[name].Hidden {
+			continue
+		}
+		first, _, _ := strings.Cut(name, " ")
+		if !seen[first] {
+			seen[first] = true
+			result = append(result, first)
+		}
+	}
+	return result
+}
+
+func (c *commands) completeCommandNames(s *state) []string {
+	return c.topLevelNames()
+}
+
+func filterPrefix(values []string, prefix string) []string {
+	var result []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			result = append(result, v)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (spec *commandSpec) findFlag(arg string) (flagSpec, bool) {
+	name := strings.TrimLeft(arg, "-")
+	if name == arg || strings.Contains(name, "=") {
+		return flagSpec{}, false
+	}
+	for _, f := range spec.Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return flagSpec{}, false
+}
+
+// complete works out what could go in place of the last word. words holds
+// everything typed after the program name, the last entry being the
+// (possibly empty) word under the cursor
+func (c *commands) complete(s *state, words []string) []string {
+
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	var typed []string
+	before := words[:len(words)-1]
+	for i := 0; i < len(before); i++ {
+		if before[i] == "--output" || before[i] == "-output" {
+			if i == len(before)-1 {
+				return filterPrefix(outputFormats, current)
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(before[i], "--output=") || strings.HasPrefix(before[i], "-output=") {
+			continue
+		}
+		typed = append(typed, before[i])
+	}
+
+	if len(typed) == 0 {
+		if strings.HasPrefix(current, "-") {
+			return filterPrefix([]string{"--output"}, current)
+		}
+		return filterPrefix(c.topLevelNames(), current)
+	}
+
+	spec, cmd, err := c.lookup(command{Name: typed[0], Args: typed[1:]})
+	if err != nil {
+		if len(typed) == 1 {
+			return filterPrefix(c.subcommands(typed[0]), current)
+		}
+		return nil
+	}
+	rest := cmd.Args
+
+	if len(rest) > 0 {
+		if f, ok := spec.findFlag(rest[len(rest)-1]); ok {
+			if _, isBool := f.Default.(bool); !isBool {
+				if f.Complete == nil {
+					return nil
+				}
+				return filterPrefix(f.Complete(s), current)
+			}
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		names := []string{"--help", "--output"}
+		for _, f := range spec.Flags {
+			names = append(names, flagName(f.Name))
+		}
+		return filterPrefix(names, current)
+	}
+
+	position := 0
+	for i := 0; i < len(rest); i++ {
+		if f, ok := spec.findFlag(rest[i]); ok {
+			if _, isBool := f.Default.(bool); !isBool {
+				i++
+			}
+			continue
+		}
+		position++
+	}
+
+	if len(spec.Args) == 0 {
+		return nil
+	}
+	if position >= len(spec.Args) {
+		last := spec.Args[len(spec.Args)-1]
+		if !last.Variadic {
+			return nil
+		}
+		position = len(spec.Args) - 1
+	}
+
+	arg := spec.Args[position]
+	if arg.Complete == nil {
+		return nil
+	}
+	return filterPrefix(arg.Complete(s), current)
+}
+
+func (c *commands) handlerComplete(s *state, cmd command) error {
+	for _, v := range c.complete(s, cmd.Args) {
+		fmt.Println(v)
+	}
+	return nil
+}
+
+// ======== Shell Scripts ========
+
+func handlerCompletion(s *state, cmd command) error {
+
+	var script string
+	switch cmd.Args[0] {
+	case "bash":
+		script = bashCompletion
+	case "zsh":
+		script = zshCompletion
+	case "fish":
+		script = fishCompletion
+	default:
+		return cmd.usageError()
+	}
+
+	_, err := io.WriteString(os.Stdout, script)
+	return err
+}
+
+// An empty answer from __complete means there is nothing gator knows about
+// for that word, so every script falls back to completing file names.
+
+const bashCompletion = `# bash completion for gator
+# load with: source <(gator completion bash)
+
+_gator() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null 2>&1; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+
+    local IFS=$'\n'
+    local candidates
+    candidates=$(gator __complete "${words[@]:1:cword}" 2>/dev/null)
+    if [[ -z "$candidates" ]]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+    else
+        COMPREPLY=($(compgen -W "$candidates" -- "$cur"))
+    fi
+
+    if declare -F __ltrim_colon_completions >/dev/null 2>&1; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+
+complete -F _gator gator
+`
+
+const zshCompletion = `#compdef gator
+# zsh completion for gator
+# load with: source <(gator completion zsh)
+
+_gator() {
+    local -a candidates
+    candidates=(${(f)"$(gator __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    if (( ${#candidates} == 0 )); then
+        _files
+        return
+    fi
+    compadd -- "${candidates[@]}"
+}
+
+if [ "$funcstack[1]" = "_gator" ]; then
+    _gator "$@"
+else
+    compdef _gator gator
+fi
+`
+
+const fishCompletion = `# fish completion for gator
+# load with: gator completion fish | source
+
+function __gator_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    set -l candidates (gator __complete $tokens[2..-1] "$current" 2>/dev/null)
+    if test (count $candidates) -eq 0
+        __fish_complete_path "$current"
+        return
+    end
+    printf '%s\n' $candidates
+end
+
+complete -c gator -f -a '(__gator_complete)'
+`
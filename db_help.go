@@ -2,8 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/colfarl/gator/internal/database"
@@ -17,19 +23,169 @@ func printUser(u database.User) {
 	fmt.Printf("Name: %v\n", u.Name.String)
 }
 
-func prettyPost(p database.Post) {
-	fmt.Printf("Title: %v\n", p.Title)
-	fmt.Printf("Description: %s\n", p.Description.String)
-	fmt.Printf("Link: %s\n", p.Url)
+// prettyPost prints a post for the terminal, raw keeps the description HTML
+// as the feed sent it, less any control characters
+func prettyPost(p postRecord, raw bool) {
+	fmt.Printf("ID: %s\n", shortID(p.ID))
+	fmt.Printf("Feed: %s\n", stripControl(p.FeedTitle))
+	if p.Highlighted {
+		fmt.Printf("Title: %v (highlighted)\n", stripControl(p.Title))
+	} else {
+		fmt.Printf("Title: %v\n", stripControl(p.Title))
+	}
+	if raw {
+		fmt.Printf("Description: %s\n", stripControl(stringValue(p.Description)))
+	} else {
+		fmt.Printf("Description:\n%s\n", renderHTML(stringValue(p.Description), terminalWidth(), stdoutIsTerminal()))
+	}
+	fmt.Printf("Link: %s\n", stripControl(p.Url))
 	fmt.Printf("Published: %v\n", p.PublishedAt)
+	if p.ReadAt != nil && p.UpdatedAt.After(*p.ReadAt) {
+		fmt.Printf("Updated: %v, after you read it\n", p.UpdatedAt)
+	}
+}
+
+// shortID is the post identifier browse prints, open and show accept it
+func shortID(id uuid.UUID) string {
+	return id.String()[:8]
+}
+
+var postIDPrefix = regexp.MustCompile(`^[0-9a-f-]{4,36}$`)
+
+// errPostNotFound tells "no such post" apart from a failing database
+var errPostNotFound = errors.New("no post in your feeds matches")
+
+// findPost resolves what the user typed (a short or full post id, or the
+// post url) to one of the posts in their feeds, hidden by a filter rule or not
+func findPost(s *state, user database.User, ref string) (database.GetPostsForUserRow, error) {
+
+	params := database.GetPostsForUserParams{
+		UserID: user.ID,
+		ShowHidden: true,
+		Limit: 2,
+	}
+
+	ref = strings.TrimSpace(ref)
+	if strings.Contains(ref, "://") {
+		params.Url = sql.NullString{String: canonicalURL(ref, nil), Valid: true}
+	} else if postIDPrefix.MatchString(strings.ToLower(ref)) {
+		params.IDPrefix = sql.NullString{String: strings.ToLower(ref), Valid: true}
+	} else {
+		return database.GetPostsForUserRow{}, fmt.Errorf("not a post id or url: %s", ref)
+	}
+
+	posts, err := s.db.GetPostsForUser(context.Background(), params)
+	if err != nil {
+		return database.GetPostsForUserRow{}, err
+	}
+	// posts stored before links were canonicalized
+	if len(posts) == 0 && params.Url.Valid && params.Url.String != ref {
+		params.Url.String = ref
+		posts, err = s.db.GetPostsForUser(context.Background(), params)
+		if err != nil {
+			return database.GetPostsForUserRow{}, err
+		}
+	}
+	if len(posts) == 0 {
+		return database.GetPostsForUserRow{}, fmt.Errorf("%w: %s", errPostNotFound, ref)
+	}
+	if len(posts) > 1 {
+		if params.Url.Valid {
+			return database.GetPostsForUserRow{}, fmt.Errorf("%s is in more than one of your feeds, use the post id", ref)
+		}
+		return database.GetPostsForUserRow{}, fmt.Errorf("%s matches more than one post, use more of the id", ref)
+	}
+	return posts[0], nil
+}
+
+// feedAddition says what addFeed had to do
+type feedAddition struct {
+	Feed     database.Feed
+	Created  bool
+	Followed bool
+}
+
+// addFeed makes sure the feed at url exists, creating it under name if it
+// doesn't, and that user follows it. Both happen in one transaction so a
+// failed follow never leaves a new feed behind, and running it again is safe
+func addFeed(s *state, user database.User, name, url string) (feedAddition, error) {
+
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return feedAddition{}, err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
+
+	var result feedAddition
+	result.Feed, err = lookupFeed(context.Background(), qtx, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		canonical := canonicalURL(url, nil)
+		params := database.CreateFeedParams{
+			ID: uuid.New(),
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+			Name: name,
+			Url: sql.NullString{String: canonical, Valid: canonical != ""},
+			UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		}
+
+		// nothing back means someone else added it since the lookup
+		result.Feed, err = qtx.CreateFeed(context.Background(), params)
+		if errors.Is(err, sql.ErrNoRows) {
+			result.Feed, err = qtx.GetFeedByURL(context.Background(), params.Url)
+		} else {
+			result.Created = true
+		}
+	}
+	if err != nil {
+		return feedAddition{}, err
+	}
+
+	result.Followed, err = followFeed(qtx, user, result.Feed.ID)
+	if err != nil {
+		return feedAddition{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return feedAddition{}, err
+	}
+	return result, nil
+}
+
+// followFeed follows feedID for user unless they already do, it reports
+// whether a new follow was created
+func followFeed(q *database.Queries, user database.User, feedID uuid.UUID) (bool, error) {
+
+	following, err := q.IsFollowingFeed(context.Background(), database.IsFollowingFeedParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil || following {
+		return false, err
+	}
+
+	params := database.CreateFeedFollowParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		FeedID: feedID,
+		UserID: user.ID,
+	}
+
+	_, err = q.CreateFeedFollow(context.Background(), params)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func middlewareLoggedIn(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {	
 
 	return func(s *state, cmd command) error {	
 
-		currUser := s.CurrentState.CurrentUserName
-		currUserInfo, err := s.db.GetUser(context.Background(), sql.NullString{String: currUser, Valid: currUser != ""})
+		currUserInfo, err := currentUser(s)
 		if err != nil {
 			return err
 		}	
@@ -38,6 +194,18 @@ func middlewareLoggedIn(handler func(s *state, cmd command, user database.User)
 	}
 }
 
+// middlewareAdmin is middlewareLoggedIn for commands only the admin may run
+func middlewareAdmin(handler func(s *state, cmd command, user database.User) error) func(*state, command) error {
+
+	return middlewareLoggedIn(func(s *state, cmd command, user database.User) error {
+
+		if !user.IsAdmin {
+			return fmt.Errorf("only an admin can run %s", cmd.Name)
+		}
+		return handler(s, cmd, user)
+	})
+}
+
 func parseTimeAnyLayout(timeStr string) (time.Time, error){
 	layouts := []string{
 		time.Layout,
@@ -71,6 +239,33 @@ func parseTimeAnyLayout(timeStr string) (time.Time, error){
 	return t, fmt.Errorf("no matching layout for: %s", timeStr)
 }
 
+// postgresTimestamp formats times sent as text for TIMESTAMP columns, which
+// have no zone
+const postgresTimestamp = "2006-01-02 15:04:05.999999"
+
+// linkKey is the dedup key of an item without a guid, also what migration 015
+// gave every post that existed before guids were stored
+func linkKey(link string) string {
+	sum := sha256.Sum256([]byte(link))
+	return "link:" + hex.EncodeToString(sum[:])
+}
+
+// postGUID identifies an item within its feed: its guid when it has one, else
+// its link, else its content for items with neither
+func postGUID(item RSSItem) string {
+
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	if item.Link != "" {
+		return linkKey(item.Link)
+	}
+	sum := sha256.Sum256([]byte(item.Title + "\n" + item.PubDate + "\n" + item.Description))
+	return "content:" + hex.EncodeToString(sum[:])
+}
+
+// scrapeFeeds fetches the stalest feed and stores it in one transaction, so a
+// failure rolls back marking it fetched as well and the next run retries it
 func scrapeFeeds(s *state) error {
 	
 	nextFeed, err := s.db.GetNextFeedToFetch(context.Background())
@@ -82,31 +277,53 @@ func scrapeFeeds(s *state) error {
 	if err != nil {
 		return err
 	}
+	rssFeed.unEscape()
+
+	feedURL, err := url.Parse(nextFeed.Url.String)
+	if err != nil {
+		return err
+	}
+	siteURL := canonicalURL(rssFeed.Channel.Link, withBase(rssFeed.Channel.Base, withBase(rssFeed.Base, feedURL)))
+	base := feedBase(rssFeed, feedURL)
+
+	tx, err := s.conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := s.db.WithTx(tx)
 	
-	err = s.db.MarkedFeedFetched(context.Background(), nextFeed.ID)
+	err = qtx.MarkedFeedFetched(context.Background(), nextFeed.ID)
 	if err != nil {
 		return err
 	}
 	
-	rssFeed.unEscape()
-	for _, v := range rssFeed.Channel.Item {
-		publishTime, err := parseTimeAnyLayout(v.PubDate)
+	if siteURL != "" {
+		err = qtx.SetFeedSiteURL(context.Background(), database.SetFeedSiteURLParams{
+			ID: nextFeed.ID,
+			SiteUrl: sql.NullString{String: siteURL, Valid: true},
+		})
 		if err != nil {
 			return err
 		}
+	}
 
-		params := database.CreatePostParams{
-			ID: uuid.New(),
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-			Title: v.Title,
-			Url: v.Link,
-			Description: sql.NullString{String: v.Description, Valid: v.Description != ""},
-			PublishedAt: publishTime,
-			FeedID: nextFeed.ID,
-		}
+	err = savePosts(qtx, nextFeed.ID, base, rssFeed.Channel.Item)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
 
-		_, err = s.db.CreatePost(context.Background(), params)
+	// the icon is fetched over the network, so it stays out of the transaction
+	if siteURL == "" {
+		siteURL = nextFeed.SiteUrl.String
+	}
+	if siteURL != "" {
+		err = updateFeedIcon(s, nextFeed.ID, siteURL)
 		if err != nil {
 			return err
 		}
@@ -114,3 +331,139 @@ func scrapeFeeds(s *state) error {
 
 	return nil
 }
+
+// contentHash covers what a publisher might fix after the fact, an item whose
+// hash changes is updated in place and its old version kept as a revision
+func contentHash(title, url, description string) string {
+	sum := sha256.Sum256([]byte(title + "\n" + url + "\n" + description))
+	return hex.EncodeToString(sum[:])
+}
+
+// savePosts stores new items of a feed in one batch and updates the ones that
+// changed, q is expected to be inside a transaction. Links, and those inside
+// descriptions, are stored resolved against the item's base, but guids and
+// content hashes come from the item as sent so they stay stable when the way
+// links are stored changes
+func savePosts(q *database.Queries, feedID uuid.UUID, base *url.URL, items []RSSItem) error {
+
+	ctx := context.Background()
+	now := time.Now()
+
+	var guids, adoptGUIDs, adoptLinkKeys []string
+	for _, v := range items {
+		guid := postGUID(v)
+		if v.Link != "" && guid != linkKey(v.Link) {
+			adoptGUIDs = append(adoptGUIDs, guid)
+			adoptLinkKeys = append(adoptLinkKeys, linkKey(v.Link))
+		}
+		guids = append(guids, guid)
+	}
+
+	if len(adoptGUIDs) > 0 {
+		err := q.AdoptPostGUIDs(ctx, database.AdoptPostGUIDsParams{
+			Guids: adoptGUIDs,
+			LinkKeys: adoptLinkKeys,
+			FeedID: feedID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	stored, err := q.GetPostHashesByGUID(ctx, database.GetPostHashesByGUIDParams{
+		FeedID: feedID,
+		Guids: guids,
+	})
+	if err != nil {
+		return err
+	}
+	existing := map[string]database.GetPostHashesByGUIDRow{}
+	for _, v := range stored {
+		existing[v.Guid] = v
+	}
+
+	// CreatePosts skips items prune deleted, so they don't come back unread
+	// on the next fetch. Once the feed stops carrying one it can be forgotten
+	if len(guids) > 0 {
+		err = q.ForgetPrunedPosts(ctx, database.ForgetPrunedPostsParams{
+			FeedID: feedID,
+			Guids: guids,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	newPosts := database.CreatePostsParams{
+		CreatedAt: now,
+		FeedID: feedID,
+	}
+	seen := map[string]bool{}
+	for i, v := range items {
+		if seen[guids[i]] {
+			continue
+		}
+		seen[guids[i]] = true
+		hash := contentHash(v.Title, v.Link, v.Description)
+		itemBase := withBase(v.Base, base)
+		link := canonicalURL(v.Link, itemBase)
+		description := resolveContentLinks(v.Description, itemBase)
+
+		if post, ok := existing[guids[i]]; ok {
+			if post.ContentHash == hash {
+				continue
+			}
+
+			err = q.CreatePostRevision(ctx, database.CreatePostRevisionParams{
+				ID: uuid.New(),
+				ReplacedAt: now,
+				PostID: post.ID,
+			})
+			if err != nil {
+				return err
+			}
+			err = q.UpdatePostContent(ctx, database.UpdatePostContentParams{
+				ID: post.ID,
+				Title: v.Title,
+				Url: link,
+				Description: sql.NullString{String: description, Valid: description != ""},
+				ContentHash: hash,
+				UpdatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// one bad date shouldn't keep the rest of the feed out forever
+		publishTime, err := parseTimeAnyLayout(v.PubDate)
+		if err != nil {
+			publishTime = now
+		}
+
+		newPosts.Ids = append(newPosts.Ids, uuid.New())
+		newPosts.Titles = append(newPosts.Titles, v.Title)
+		newPosts.Urls = append(newPosts.Urls, link)
+		newPosts.Descriptions = append(newPosts.Descriptions, description)
+		newPosts.PublishedAts = append(newPosts.PublishedAts, publishTime.Format(postgresTimestamp))
+		newPosts.Guids = append(newPosts.Guids, guids[i])
+		newPosts.ContentHashes = append(newPosts.ContentHashes, hash)
+	}
+
+	if len(newPosts.Ids) == 0 {
+		return nil
+	}
+	_, err = q.CreatePosts(ctx, newPosts)
+	if err != nil {
+		return err
+	}
+
+	// star and mark-read rules act once, as posts arrive, and leave them to
+	// the user from then on
+	_, err = q.ApplyFilterRules(ctx, database.ApplyFilterRulesParams{
+		AppliedAt: now,
+		PostIds: newPosts.Ids,
+	})
+	return err
+}
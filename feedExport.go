@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+)
+
+const generatorURL = "https://github.com/colfarl/gator"
+
+// ======== RSS 2.0 ========
+
+type rssOutFeed struct {
+	XMLName xml.Name      `xml:"rss"`
+	Version string        `xml:"version,attr"`
+	Channel rssOutChannel `xml:"channel"`
+}
+
+type rssOutChannel struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	Description   string       `xml:"description"`
+	LastBuildDate string       `xml:"lastBuildDate"`
+	Generator     string       `xml:"generator"`
+	Items         []rssOutItem `xml:"item"`
+}
+
+type rssOutItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description,omitempty"`
+	PubDate     string `xml:"pubDate"`
+	GUID        struct {
+		IsPermaLink string `xml:"isPermaLink,attr"`
+		Value       string `xml:",chardata"`
+	} `xml:"guid"`
+	Source struct {
+		URL   string `xml:"url,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"source"`
+}
+
+// ======== Atom ========
+
+type atomOutFeed struct {
+	XMLName xml.Name       `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string         `xml:"id"`
+	Title   string         `xml:"title"`
+	Updated string         `xml:"updated"`
+	Author  atomOutAuthor  `xml:"author"`
+	Link    []atomOutLink  `xml:"link"`
+	Entries []atomOutEntry `xml:"entry"`
+}
+
+type atomOutAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomOutLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomOutText struct {
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type atomOutEntry struct {
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published"`
+	Link      atomOutLink    `xml:"link"`
+	Summary   *atomOutText   `xml:"summary,omitempty"`
+	Source    *atomOutSource `xml:"source,omitempty"`
+}
+
+type atomOutSource struct {
+	ID    string        `xml:"id"`
+	Title string        `xml:"title"`
+	Link  []atomOutLink `xml:"link"`
+}
+
+// ======== Rendering ========
+
+func timelineTitle(user database.User, tag string) string {
+	if tag != "" {
+		return fmt.Sprintf("%s's gator timeline: %s", user.Name.String, tag)
+	}
+	return fmt.Sprintf("%s's gator timeline", user.Name.String)
+}
+
+func buildRSSTimeline(user database.User, tag string, posts []database.GetPostsForUserRow) rssOutFeed {
+
+	var out rssOutFeed
+	out.Version = "2.0"
+	out.Channel.Title = timelineTitle(user, tag)
+	out.Channel.Link = generatorURL
+	out.Channel.Description = "Posts from the feeds followed in gator"
+	out.Channel.LastBuildDate = time.Now().UTC().Format(time.RFC1123Z)
+	out.Channel.Generator = "gator"
+
+	for _, p := range posts {
+		var item rssOutItem
+		item.Title = p.Title
+		item.Link = p.Url
+		item.Description = p.Description.String
+		item.PubDate = p.PublishedAt.UTC().Format(time.RFC1123Z)
+		item.GUID.IsPermaLink = "false"
+		item.GUID.Value = "urn:uuid:" + p.ID.String()
+		item.Source.URL = p.FeedUrl.String
+		item.Source.Value = p.FeedTitle
+		out.Channel.Items = append(out.Channel.Items, item)
+	}
+
+	return out
+}
+
+func buildAtomTimeline(user database.User, tag string, posts []database.GetPostsForUserRow) atomOutFeed {
+
+	var out atomOutFeed
+	out.ID = "urn:uuid:" + user.ID.String()
+	out.Title = timelineTitle(user, tag)
+	out.Author.Name = user.Name.String
+	out.Link = []atomOutLink{{Href: generatorURL}}
+	out.Updated = time.Now().UTC().Format(time.RFC3339)
+	// posts come newest published first, which isn't always the last updated
+	if len(posts) > 0 {
+		updated := posts[0].UpdatedAt
+		for _, p := range posts[1:] {
+			if p.UpdatedAt.After(updated) {
+				updated = p.UpdatedAt
+			}
+		}
+		out.Updated = updated.UTC().Format(time.RFC3339)
+	}
+
+	for _, p := range posts {
+		entry := atomOutEntry{
+			ID: "urn:uuid:" + p.ID.String(),
+			Title: p.Title,
+			Updated: p.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: p.PublishedAt.UTC().Format(time.RFC3339),
+			Link: atomOutLink{Rel: "alternate", Href: p.Url},
+			Source: &atomOutSource{
+				ID: p.FeedUrl.String,
+				Title: p.FeedTitle,
+				Link: []atomOutLink{{Rel: "self", Href: p.FeedUrl.String}},
+			},
+		}
+		if p.Description.Valid {
+			entry.Summary = &atomOutText{Type: "html", Value: p.Description.String}
+		}
+		out.Entries = append(out.Entries, entry)
+	}
+
+	return out
+}
+
+func writeTimelineFeed(s *state, w io.Writer, user database.User, format, tag string, limit int32) error {
+
+	if format != "atom" && format != "rss" {
+		return fmt.Errorf("unknown feed format: %s (want atom or rss)", format)
+	}
+
+	params := database.GetPostsForUserParams{
+		UserID: user.ID,
+		Tag: sql.NullString{String: tag, Valid: tag != ""},
+		Limit: limit,
+	}
+
+	posts, err := s.db.GetPostsForUser(context.Background(), params)
+	if err != nil {
+		return err
+	}
+
+	var doc any
+	if format == "atom" {
+		doc = buildAtomTimeline(user, tag, posts)
+	} else {
+		doc = buildRSSTimeline(user, tag, posts)
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// writeTimelineFile replaces path in one rename so readers never see a half
+// written document
+func writeTimelineFile(s *state, path string, user database.User, format, tag string, limit int32) error {
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gator-feed-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = writeTimelineFeed(s, tmp, user, format, tag, limit)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	feedIconMaxAge  = 30 * 24 * time.Hour
+	feedIconMaxSize = 100 << 10
+)
+
+// updateFeedIcon fetches the site's favicon at most once every feedIconMaxAge.
+// Sites without one are stored with no data so they are not asked every scrape
+func updateFeedIcon(s *state, feedID uuid.UUID, siteURL string) error {
+
+	fetchedAt, err := s.db.GetFeedIconFetchedAt(context.Background(), feedID)
+	if err == nil && time.Since(fetchedAt) < feedIconMaxAge {
+		return nil
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	mimeType, data := fetchFavicon(siteURL)
+	return s.db.SetFeedIcon(context.Background(), database.SetFeedIconParams{
+		FeedID: feedID,
+		FetchedAt: time.Now(),
+		MimeType: sql.NullString{String: mimeType, Valid: mimeType != ""},
+		Data: data,
+	})
+}
+
+func fetchFavicon(siteURL string) (string, []byte) {
+
+	base, err := url.Parse(siteURL)
+	if err != nil || base.Host == "" {
+		return "", nil
+	}
+	iconURL := base.ResolveReference(&url.URL{Path: "/favicon.ico"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", iconURL.String(), nil)
+	if err != nil {
+		return "", nil
+	}
+	req.Header.Set("User-Agent", "gator")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, feedIconMaxSize+1))
+	if err != nil || len(data) == 0 || len(data) > feedIconMaxSize {
+		return "", nil
+	}
+
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", nil
+	}
+	return mimeType, data
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Fever API (https://feedafever.com/api), spoken by Reeder, Unread and
+// friends. Everything goes to one url: the api_key form value identifies the
+// user and the other query keys say what to return or change. Feeds and items
+// use the seq columns as their integer ids, groups are the user's tags
+
+const (
+	feverPrefix     = "/fever/"
+	feverAPIVersion = 3
+	feverPageSize   = 50
+)
+
+type feverGroup struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int64  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int64  `json:"id"`
+	FaviconID         int64  `json:"favicon_id"`
+	Title             string `json:"title"`
+	Url               string `json:"url"`
+	SiteUrl           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverFavicon struct {
+	ID   int64  `json:"id"`
+	Data string `json:"data"`
+}
+
+type feverItem struct {
+	ID            int64  `json:"id"`
+	FeedID        int64  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	Url           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+// feverGroupID gives a tag a stable positive id, Fever reserves 0 for "all"
+func feverGroupID(tag string) int64 {
+	id := int64(crc32.ChecksumIEEE([]byte(tag)) & 0x7fffffff)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func joinIDs(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, v := range ids {
+		parts[i] = strconv.FormatInt(v, 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func parseIDs(v string) []int64 {
+	var ids []int64
+	for _, part := range strings.Split(v, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+		if len(ids) == feverPageSize {
+			break
+		}
+	}
+	return ids
+}
+
+// formInt reads an optional number, anything else counts as missing
+func formInt(r *http.Request, key string) sql.NullInt64 {
+	n, err := strconv.ParseInt(r.Form.Get(key), 10, 64)
+	if err != nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: n, Valid: true}
+}
+
+func registerFeverRoutes(mux *http.ServeMux, s *state) {
+	mux.HandleFunc(feverPrefix, handleFever(s))
+	mux.HandleFunc(strings.TrimSuffix(feverPrefix, "/"), handleFever(s))
+}
+
+func handleFever(s *state) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		resp := map[string]any{"api_version": feverAPIVersion, "auth": 0}
+
+		err := r.ParseForm()
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, resp)
+			return
+		}
+
+		if r.Form.Get("api_key") == "" {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+
+		user, err := userForFeverKey(s, r.Form.Get("api_key"))
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		resp["auth"] = 1
+
+		fv := &feverRequest{s: s, r: r, user: user, resp: resp}
+		err = fv.run()
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+type feverRequest struct {
+	s    *state
+	r    *http.Request
+	user database.User
+	resp map[string]any
+
+	feeds []database.GetFeverFeedsRow
+	tags  []database.GetFeedTagsForUserRow
+}
+
+func (fv *feverRequest) has(key string) bool {
+	_, ok := fv.r.Form[key]
+	return ok
+}
+
+func (fv *feverRequest) run() error {
+
+	var err error
+	fv.feeds, err = fv.s.db.GetFeverFeeds(fv.r.Context(), fv.user.ID)
+	if err != nil {
+		return err
+	}
+	fv.tags, err = fv.s.db.GetFeedTagsForUser(fv.r.Context(), fv.user.ID)
+	if err != nil {
+		return err
+	}
+
+	var lastRefreshed int64
+	for _, v := range fv.feeds {
+		if v.LastFetchedAt.Valid {
+			lastRefreshed = max(lastRefreshed, v.LastFetchedAt.Time.Unix())
+		}
+	}
+	fv.resp["last_refreshed_on_time"] = lastRefreshed
+
+	// changes first, so anything asked for alongside them is already up to date
+	if fv.has("mark") {
+		err = fv.mark()
+		if err != nil {
+			return err
+		}
+	}
+
+	if fv.has("groups") {
+		fv.resp["groups"] = fv.groups()
+		fv.resp["feeds_groups"] = fv.feedsGroups()
+	}
+	if fv.has("feeds") {
+		fv.resp["feeds"] = fv.feedList()
+		fv.resp["feeds_groups"] = fv.feedsGroups()
+	}
+	if fv.has("favicons") {
+		err = fv.favicons()
+		if err != nil {
+			return err
+		}
+	}
+	if fv.has("items") {
+		err = fv.items()
+		if err != nil {
+			return err
+		}
+	}
+	if fv.has("links") {
+		fv.resp["links"] = []any{}
+	}
+	if fv.has("unread_item_ids") {
+		err = fv.unreadItemIDs()
+		if err != nil {
+			return err
+		}
+	}
+	if fv.has("saved_item_ids") {
+		err = fv.savedItemIDs()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fv *feverRequest) groups() []feverGroup {
+	groups := []feverGroup{}
+	var names []string
+	for _, v := range fv.tags {
+		names = append(names, v.Name)
+	}
+	for _, v := range uniqueTags(names) {
+		groups = append(groups, feverGroup{ID: feverGroupID(v), Title: v})
+	}
+	return groups
+}
+
+func (fv *feverRequest) feedsGroups() []feverFeedsGroup {
+
+	seqs := map[uuid.UUID]int64{}
+	for _, v := range fv.feeds {
+		seqs[v.ID] = v.Seq
+	}
+
+	var order []string
+	members := map[string][]int64{}
+	for _, v := range fv.tags {
+		seq, ok := seqs[v.FeedID]
+		if !ok {
+			continue
+		}
+		if _, seen := members[v.Name]; !seen {
+			order = append(order, v.Name)
+		}
+		members[v.Name] = append(members[v.Name], seq)
+	}
+
+	groups := []feverFeedsGroup{}
+	for _, name := range order {
+		groups = append(groups, feverFeedsGroup{GroupID: feverGroupID(name), FeedIDs: joinIDs(members[name])})
+	}
+	return groups
+}
+
+func (fv *feverRequest) feedList() []feverFeed {
+	feeds := []feverFeed{}
+	for _, v := range fv.feeds {
+		feed := feverFeed{
+			ID: v.Seq,
+			Title: v.Title,
+			Url: v.Url.String,
+			SiteUrl: v.SiteUrl.String,
+		}
+		if v.HasIcon {
+			feed.FaviconID = v.Seq
+		}
+		if v.LastFetchedAt.Valid {
+			feed.LastUpdatedOnTime = v.LastFetchedAt.Time.Unix()
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds
+}
+
+func (fv *feverRequest) favicons() error {
+
+	icons, err := fv.s.db.GetFeverFavicons(fv.r.Context(), fv.user.ID)
+	if err != nil {
+		return err
+	}
+
+	favicons := []feverFavicon{}
+	for _, v := range icons {
+		favicons = append(favicons, feverFavicon{
+			ID: v.Seq,
+			Data: v.MimeType.String + ";base64," + base64.StdEncoding.EncodeToString(v.Data),
+		})
+	}
+	fv.resp["favicons"] = favicons
+	return nil
+}
+
+// items pages forwards with since_id and backwards with max_id, or fetches
+// specific items with with_ids. Each call returns at most feverPageSize
+func (fv *feverRequest) items() error {
+
+	params := database.GetFeverItemsParams{
+		UserID: fv.user.ID,
+		SinceID: formInt(fv.r, "since_id"),
+		MaxID: formInt(fv.r, "max_id"),
+	}
+	if v := fv.r.Form.Get("with_ids"); v != "" {
+		// an empty list matches nothing rather than everything
+		params.WithIds = append([]int64{}, parseIDs(v)...)
+	}
+
+	posts, err := fv.s.db.GetFeverItems(fv.r.Context(), params)
+	if err != nil {
+		return err
+	}
+	total, err := fv.s.db.CountFeverItems(fv.r.Context(), fv.user.ID)
+	if err != nil {
+		return err
+	}
+
+	items := []feverItem{}
+	for _, v := range posts {
+		items = append(items, feverItem{
+			ID: v.Seq,
+			FeedID: v.FeedSeq,
+			Title: v.Title,
+			HTML: v.Description.String,
+			Url: v.Url,
+			IsSaved: boolInt(v.StarredAt.Valid),
+			IsRead: boolInt(v.ReadAt.Valid),
+			CreatedOnTime: v.PublishedAt.Unix(),
+		})
+	}
+	fv.resp["items"] = items
+	fv.resp["total_items"] = total
+	return nil
+}
+
+func (fv *feverRequest) unreadItemIDs() error {
+	ids, err := fv.s.db.GetFeverUnreadItemIDs(fv.r.Context(), fv.user.ID)
+	if err != nil {
+		return err
+	}
+	fv.resp["unread_item_ids"] = joinIDs(ids)
+	return nil
+}
+
+func (fv *feverRequest) savedItemIDs() error {
+	ids, err := fv.s.db.GetFeverSavedItemIDs(fv.r.Context(), fv.user.ID)
+	if err != nil {
+		return err
+	}
+	fv.resp["saved_item_ids"] = joinIDs(ids)
+	return nil
+}
+
+// mark handles mark=item (as read, unread, saved or unsaved) and mark=feed or
+// mark=group (as read, for items that arrived before the before timestamp).
+// Ids the user can't see are ignored, like Fever does
+func (fv *feverRequest) mark() error {
+
+	id := formInt(fv.r, "id")
+	if !id.Valid {
+		return nil
+	}
+	as := fv.r.Form.Get("as")
+
+	switch fv.r.Form.Get("mark") {
+	case "item":
+		postID, err := fv.s.db.GetPostIDBySeq(fv.r.Context(), database.GetPostIDBySeqParams{
+			UserID: fv.user.ID,
+			Seq: id.Int64,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		now := sql.NullTime{Time: time.Now(), Valid: true}
+		switch as {
+		case "read", "unread":
+			params := database.SetPostReadParams{UserID: fv.user.ID, PostID: postID}
+			if as == "read" {
+				params.ReadAt = now
+			}
+			err = fv.s.db.SetPostRead(fv.r.Context(), params)
+			if err != nil {
+				return err
+			}
+			return fv.unreadItemIDs()
+		case "saved", "unsaved":
+			params := database.SetPostStarredParams{UserID: fv.user.ID, PostID: postID}
+			if as == "saved" {
+				params.StarredAt = now
+			}
+			err = fv.s.db.SetPostStarred(fv.r.Context(), params)
+			if err != nil {
+				return err
+			}
+			return fv.savedItemIDs()
+		}
+
+	case "feed", "group":
+		if as != "read" {
+			return nil
+		}
+
+		params := database.MarkPostsReadBeforeParams{
+			ReadAt: time.Now(),
+			UserID: fv.user.ID,
+			Before: time.Now(),
+		}
+		if before := formInt(fv.r, "before"); before.Valid {
+			params.Before = time.Unix(before.Int64, 0)
+		}
+
+		if fv.r.Form.Get("mark") == "feed" {
+			params.FeedSeq = id
+		} else if id.Int64 != 0 {
+			// group 0 is every feed, other groups are looked up by their tag
+			tag, ok := fv.tagForGroup(id.Int64)
+			if !ok {
+				return nil
+			}
+			params.Tag = sql.NullString{String: tag, Valid: true}
+		}
+
+		err := fv.s.db.MarkPostsReadBefore(fv.r.Context(), params)
+		if err != nil {
+			return err
+		}
+		return fv.unreadItemIDs()
+	}
+	return nil
+}
+
+func (fv *feverRequest) tagForGroup(id int64) (string, bool) {
+	for _, v := range fv.tags {
+		if feverGroupID(v.Name) == id {
+			return v.Name, true
+		}
+	}
+	return "", false
+}
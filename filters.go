@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Filter rules match posts by a title regex and/or a keyword found in the
+// title or description, in one feed or all of them. hide and highlight are
+// worked out whenever posts are listed, star and mark-read are applied once
+// to posts as agg stores them. The matching itself is done by postgres, in
+// GetPostsForUser and ApplyFilterRules
+
+var filterActions = []string{"hide", "highlight", "star", "mark-read"}
+
+func validFilterAction(action string) bool {
+	for _, v := range filterActions {
+		if v == action {
+			return true
+		}
+	}
+	return false
+}
+
+// describeFilterRule reads a rule back as a sentence, "hide posts whose title
+// matches /.../ in Feed"
+func describeFilterRule(r filterRecord) string {
+
+	var conditions []string
+	if r.TitleRegex != nil {
+		conditions = append(conditions, fmt.Sprintf("whose title matches /%s/", *r.TitleRegex))
+	}
+	if r.Keyword != nil {
+		conditions = append(conditions, fmt.Sprintf("mentioning %q", *r.Keyword))
+	}
+
+	where := "in all feeds"
+	if r.FeedName != nil {
+		where = "in " + *r.FeedName
+	}
+	return fmt.Sprintf("%s posts %s %s", r.Action, strings.Join(conditions, " and "), where)
+}
+
+// invalidRegexError picks out postgres refusing a rule's title regex, either
+// failing to compile it (invalid_regular_expression) or the check on the
+// column itself
+func invalidRegexError(err error) (string, bool) {
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return "", false
+	}
+	if pqErr.Code == "2201B" || (pqErr.Code == "23514" && pqErr.Constraint == "filter_rules_title_regex_check") {
+		return pqErr.Message, true
+	}
+	return "", false
+}
+
+func handlerFilterAdd(s *state, cmd command, user database.User) error {
+
+	action := cmd.flagString("action")
+	if !validFilterAction(action) {
+		return fmt.Errorf("--action must be one of %s", strings.Join(filterActions, "|"))
+	}
+
+	titleRegex, keyword := cmd.flagString("title-regex"), strings.TrimSpace(cmd.flagString("keyword"))
+	if titleRegex == "" && keyword == "" {
+		return fmt.Errorf("a filter needs a --title-regex, a --keyword or both")
+	}
+	params := database.CreateFilterRuleParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UserID: user.ID,
+		TitleRegex: sql.NullString{String: titleRegex, Valid: titleRegex != ""},
+		Keyword: sql.NullString{String: keyword, Valid: keyword != ""},
+		Action: action,
+	}
+
+	var feed database.Feed
+	if feedURL := cmd.flagString("feed"); feedURL != "" {
+		var err error
+		feed, err = lookupFeed(context.Background(), s.db, feedURL)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no feed with url %s", feedURL)
+		}
+		if err != nil {
+			return err
+		}
+		params.FeedID = uuid.NullUUID{UUID: feed.ID, Valid: true}
+	}
+
+	// the regex runs in postgres, so postgres is what checks it as the rule is
+	// stored
+	rule, err := s.db.CreateFilterRule(context.Background(), params)
+	if msg, ok := invalidRegexError(err); ok {
+		return fmt.Errorf("invalid --title-regex: %s", msg)
+	}
+	if err != nil {
+		return err
+	}
+
+	record := newFilterRecord(database.GetFilterRulesForUserRow{
+		ID: rule.ID,
+		CreatedAt: rule.CreatedAt,
+		UserID: rule.UserID,
+		FeedID: rule.FeedID,
+		TitleRegex: rule.TitleRegex,
+		Keyword: rule.Keyword,
+		Action: rule.Action,
+		FeedName: sql.NullString{String: feed.Name, Valid: rule.FeedID.Valid},
+		FeedUrl: feed.Url,
+	})
+	fmt.Printf("added filter %s: %s\n", shortID(rule.ID), describeFilterRule(record))
+	if action == "star" || action == "mark-read" {
+		fmt.Println("it applies to posts fetched from now on")
+	}
+	return nil
+}
+
+func handlerFilterList(s *state, cmd command, user database.User) error {
+
+	rules, err := s.db.GetFilterRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	var records []filterRecord
+	for _, v := range rules {
+		records = append(records, newFilterRecord(v))
+	}
+
+	return printListing(cmd.Output, records, func(records []filterRecord) {
+		if len(records) == 0 {
+			fmt.Println("no filters")
+			return
+		}
+		for _, v := range records {
+			fmt.Printf(" * %s  %s\n", shortID(v.ID), describeFilterRule(v))
+		}
+	})
+}
+
+// findFilterRule matches a rule by a prefix of its id, at least four
+// characters like tokens
+func findFilterRule(rules []database.GetFilterRulesForUserRow, ref string) (database.GetFilterRulesForUserRow, error) {
+
+	var matches []database.GetFilterRulesForUserRow
+	for _, v := range rules {
+		if len(ref) >= 4 && strings.HasPrefix(v.ID.String(), strings.ToLower(ref)) {
+			matches = append(matches, v)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return database.GetFilterRulesForUserRow{}, fmt.Errorf("no filter with an id starting %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return database.GetFilterRulesForUserRow{}, fmt.Errorf("%q matches %d filters, use more of the id from gator filter list", ref, len(matches))
+	}
+}
+
+func handlerFilterRemove(s *state, cmd command, user database.User) error {
+
+	rules, err := s.db.GetFilterRulesForUser(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	rule, err := findFilterRule(rules, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.DeleteFilterRule(context.Background(), database.DeleteFilterRuleParams{
+		UserID: user.ID,
+		ID: rule.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("removed filter %s: %s\n", shortID(rule.ID), describeFilterRule(newFilterRecord(rule)))
+	return nil
+}
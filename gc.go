@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/colfarl/gator/internal/database"
+)
+
+// Feeds are shared: whoever added one first is only recorded as its creator,
+// and it lives for as long as somebody follows it. Once the last follower is
+// gone agg stops fetching it, and gc deletes it along with its posts
+
+func collectOrphanedFeeds(s *state, dryRun bool) ([]database.GetOrphanedFeedsRow, error) {
+
+	orphans, err := s.db.GetOrphanedFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if dryRun || len(orphans) == 0 {
+		return orphans, nil
+	}
+
+	_, err = s.db.DeleteOrphanedFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return orphans, nil
+}
+
+func printOrphanedFeeds(orphans []database.GetOrphanedFeedsRow, verb string) {
+	for _, v := range orphans {
+		fmt.Printf("%s %s (%s), %d posts\n", verb, v.Name, v.Url.String, v.PostCount)
+	}
+}
+
+func handlerGC(s *state, cmd command) error {
+
+	dryRun := cmd.flagBool("dry-run")
+	orphans, err := collectOrphanedFeeds(s, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("every feed has a follower, nothing to collect")
+		return nil
+	}
+	if dryRun {
+		printOrphanedFeeds(orphans, "would delete")
+		return nil
+	}
+	printOrphanedFeeds(orphans, "deleted")
+	return nil
+}
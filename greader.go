@@ -0,0 +1,828 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Google Reader API, the dialect FreshRSS and Miniflux also speak. Clients
+// are given http://<host>/greader as the server and log in with the user name
+// and an API token as the password. Feeds and items use the seq columns as
+// their ids, labels are the user's tags
+
+const (
+	greaderPrefix       = "/greader"
+	greaderAPI          = greaderPrefix + "/reader/api/0"
+	greaderStatePrefix  = "user/-/state/com.google/"
+	greaderLabelPrefix  = "user/-/label/"
+	greaderFeedPrefix   = "feed/"
+	greaderItemPrefix   = "tag:google.com,2005:reader/item/"
+	greaderReadingList  = greaderStatePrefix + "reading-list"
+	greaderRead         = greaderStatePrefix + "read"
+	greaderStarred      = greaderStatePrefix + "starred"
+	greaderKeptUnread   = greaderStatePrefix + "kept-unread"
+	greaderDefaultCount = 20
+	greaderMaxCount     = 1000
+)
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type greaderSubscription struct {
+	ID         string            `json:"id"`
+	Title      string            `json:"title"`
+	Categories []greaderCategory `json:"categories"`
+	Url        string            `json:"url"`
+	HtmlUrl    string            `json:"htmlUrl"`
+	IconUrl    string            `json:"iconUrl"`
+}
+
+type greaderTag struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderUnreadCount struct {
+	ID                      string `json:"id"`
+	Count                   int64  `json:"count"`
+	NewestItemTimestampUsec string `json:"newestItemTimestampUsec"`
+}
+
+type greaderItemRef struct {
+	ID              string   `json:"id"`
+	DirectStreamIDs []string `json:"directStreamIds"`
+	TimestampUsec   string   `json:"timestampUsec"`
+}
+
+type greaderContent struct {
+	Direction string `json:"direction"`
+	Content   string `json:"content"`
+}
+
+type greaderOrigin struct {
+	StreamID string `json:"streamId"`
+	Title    string `json:"title"`
+	HtmlUrl  string `json:"htmlUrl"`
+}
+
+type greaderItem struct {
+	ID            string         `json:"id"`
+	CrawlTimeMsec string         `json:"crawlTimeMsec"`
+	TimestampUsec string         `json:"timestampUsec"`
+	Published     int64          `json:"published"`
+	Updated       int64          `json:"updated"`
+	Title         string         `json:"title"`
+	Canonical     []greaderLink  `json:"canonical"`
+	Alternate     []greaderLink  `json:"alternate"`
+	Summary       greaderContent `json:"summary"`
+	Categories    []string       `json:"categories"`
+	Origin        greaderOrigin  `json:"origin"`
+	Author        string         `json:"author"`
+}
+
+type greaderStreamContents struct {
+	Direction    string        `json:"direction"`
+	ID           string        `json:"id"`
+	Updated      int64         `json:"updated"`
+	Items        []greaderItem `json:"items"`
+	Continuation string        `json:"continuation,omitempty"`
+}
+
+// ======== Helpers ========
+
+func greaderFeedID(seq int64) string {
+	return greaderFeedPrefix + strconv.FormatInt(seq, 10)
+}
+
+func greaderItemID(seq int64) string {
+	return fmt.Sprintf("%s%016x", greaderItemPrefix, seq)
+}
+
+// parseGReaderItemID accepts the long tag: form (hex) and the short decimal one
+func parseGReaderItemID(v string) (int64, bool) {
+	if hexID, ok := strings.CutPrefix(v, greaderItemPrefix); ok {
+		n, err := strconv.ParseUint(hexID, 16, 64)
+		return int64(n), err == nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	return n, err == nil
+}
+
+// normalizeStreamID replaces the user id clients sometimes put in
+// user/<id>/... with the "-" that means the current user
+func normalizeStreamID(v string) string {
+	parts := strings.SplitN(v, "/", 3)
+	if len(parts) == 3 && parts[0] == "user" {
+		return "user/-/" + parts[2]
+	}
+	return v
+}
+
+func usec(t time.Time) string {
+	return strconv.FormatInt(t.UnixMicro(), 10)
+}
+
+// greaderStream narrows GetGReaderItemsParams to what a stream id names
+func greaderStream(params *database.GetGReaderItemsParams, streamID string) bool {
+
+	streamID = normalizeStreamID(streamID)
+	switch {
+	case streamID == "" || streamID == greaderReadingList:
+	case streamID == greaderStarred:
+		params.Starred = sql.NullBool{Bool: true, Valid: true}
+	case streamID == greaderRead:
+		params.Read = sql.NullBool{Bool: true, Valid: true}
+	case streamID == greaderKeptUnread:
+		params.Read = sql.NullBool{Bool: false, Valid: true}
+	case strings.HasPrefix(streamID, greaderLabelPrefix):
+		params.Tag = sql.NullString{String: strings.TrimPrefix(streamID, greaderLabelPrefix), Valid: true}
+	case strings.HasPrefix(streamID, greaderFeedPrefix):
+		seq, err := strconv.ParseInt(strings.TrimPrefix(streamID, greaderFeedPrefix), 10, 64)
+		if err != nil {
+			return false
+		}
+		params.FeedSeq = sql.NullInt64{Int64: seq, Valid: true}
+	default:
+		return false
+	}
+	return true
+}
+
+func greaderAuthToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "GoogleLogin") {
+		return strings.TrimPrefix(strings.TrimSpace(token), "auth=")
+	}
+	return bearerToken(r)
+}
+
+func greaderLoggedIn(s *state, handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		token := greaderAuthToken(r)
+		if token == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := userForAPIToken(s, token)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		err = r.ParseForm()
+		if err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+func writeOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+}
+
+// greaderLabels maps feed seq to the user's tags on that feed
+func greaderLabels(s *state, ctx context.Context, user database.User) (map[int64][]string, []database.GetFeverFeedsRow, error) {
+
+	feeds, err := s.db.GetFeverFeeds(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tags, err := s.db.GetFeedTagsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seqs := map[uuid.UUID]int64{}
+	for _, v := range feeds {
+		seqs[v.ID] = v.Seq
+	}
+	labels := map[int64][]string{}
+	for _, v := range tags {
+		if seq, ok := seqs[v.FeedID]; ok {
+			labels[seq] = append(labels[seq], v.Name)
+		}
+	}
+	return labels, feeds, nil
+}
+
+// resolveGReaderFeed finds the feed a subscription/edit s= names, either
+// feed/<seq> from subscription/list or feed/<url> for a new subscription
+func resolveGReaderFeed(s *state, ctx context.Context, streamID string) (uuid.UUID, string, error) {
+
+	ref := strings.TrimPrefix(normalizeStreamID(streamID), greaderFeedPrefix)
+	if seq, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		id, err := s.db.GetFeedIDBySeq(ctx, seq)
+		return id, "", err
+	}
+
+	feed, err := lookupFeed(ctx, s.db, ref)
+	return feed.ID, ref, err
+}
+
+// ======== Routes ========
+
+func registerGReaderRoutes(mux *http.ServeMux, s *state) {
+	mux.HandleFunc(greaderPrefix+"/accounts/ClientLogin", greaderClientLogin(s))
+	mux.HandleFunc("GET "+greaderAPI+"/token", greaderLoggedIn(s, greaderToken))
+	mux.HandleFunc("GET "+greaderAPI+"/user-info", greaderLoggedIn(s, greaderUserInfo))
+	mux.HandleFunc("GET "+greaderAPI+"/subscription/list", greaderLoggedIn(s, greaderSubscriptionList(s)))
+	mux.HandleFunc("POST "+greaderAPI+"/subscription/edit", greaderLoggedIn(s, greaderSubscriptionEdit(s)))
+	mux.HandleFunc("POST "+greaderAPI+"/subscription/quickadd", greaderLoggedIn(s, greaderQuickAdd(s)))
+	mux.HandleFunc("GET "+greaderAPI+"/tag/list", greaderLoggedIn(s, greaderTagList(s)))
+	mux.HandleFunc("GET "+greaderAPI+"/unread-count", greaderLoggedIn(s, greaderUnreadCounts(s)))
+	mux.HandleFunc("GET "+greaderAPI+"/stream/items/ids", greaderLoggedIn(s, greaderItemIDs(s)))
+	mux.HandleFunc(greaderAPI+"/stream/items/contents", greaderLoggedIn(s, greaderItemContents(s)))
+	mux.HandleFunc("GET "+greaderAPI+"/stream/contents", greaderLoggedIn(s, greaderStreamContentsHandler(s)))
+	mux.HandleFunc("GET "+greaderAPI+"/stream/contents/{stream...}", greaderLoggedIn(s, greaderStreamContentsHandler(s)))
+	mux.HandleFunc("POST "+greaderAPI+"/edit-tag", greaderLoggedIn(s, greaderEditTag(s)))
+	mux.HandleFunc("POST "+greaderAPI+"/mark-all-as-read", greaderLoggedIn(s, greaderMarkAllAsRead(s)))
+}
+
+// greaderClientLogin checks Email/Passwd (user name/API token) and hands the
+// token back as the Auth value clients send from then on
+func greaderClientLogin(s *state) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		err := r.ParseForm()
+		if err != nil {
+			http.Error(w, "Error=BadAuthentication", http.StatusBadRequest)
+			return
+		}
+
+		token := r.Form.Get("Passwd")
+		user, err := userForAPIToken(s, token)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			writeDBError(w, err)
+			return
+		}
+		if err != nil || token == "" || !strings.EqualFold(r.Form.Get("Email"), user.Name.String) {
+			http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+			return
+		}
+
+		if r.Form.Get("output") == "json" {
+			writeJSON(w, http.StatusOK, map[string]string{"SID": token, "LSID": token, "Auth": token})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", token, token, token)
+	}
+}
+
+// greaderToken is the T value clients echo back on edits. Requests are already
+// authenticated by the Authorization header, so it is not checked
+func greaderToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	sum := sha256.Sum256([]byte(user.ID.String()))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, hex.EncodeToString(sum[:])[:57])
+}
+
+func greaderUserInfo(w http.ResponseWriter, r *http.Request, user database.User) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"userId": user.ID.String(),
+		"userName": user.Name.String,
+		"userProfileId": user.ID.String(),
+		"userEmail": "",
+	})
+}
+
+func greaderSubscriptionList(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		labels, feeds, err := greaderLabels(s, r.Context(), user)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		subscriptions := []greaderSubscription{}
+		for _, v := range feeds {
+			sub := greaderSubscription{
+				ID: greaderFeedID(v.Seq),
+				Title: v.Title,
+				Categories: []greaderCategory{},
+				Url: v.Url.String,
+				HtmlUrl: v.SiteUrl.String,
+			}
+			for _, tag := range labels[v.Seq] {
+				sub.Categories = append(sub.Categories, greaderCategory{ID: greaderLabelPrefix + tag, Label: tag})
+			}
+			subscriptions = append(subscriptions, sub)
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"subscriptions": subscriptions})
+	}
+}
+
+func greaderSubscribe(s *state, ctx context.Context, user database.User, streamID, title string) (uuid.UUID, error) {
+
+	feedID, feedURL, err := resolveGReaderFeed(s, ctx, streamID)
+	if errors.Is(err, sql.ErrNoRows) && feedURL != "" {
+		if title == "" {
+			title = feedURL
+		}
+		added, err := addFeed(s, user, title, feedURL)
+		return added.Feed.ID, err
+	}
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	_, err = followFeed(s.db, user, feedID)
+	return feedID, err
+}
+
+// greaderSubscriptionEdit handles ac=subscribe, unsubscribe and edit, with t
+// for the title and a/r to add or remove labels
+func greaderSubscriptionEdit(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		action := r.Form.Get("ac")
+		title := r.Form.Get("t")
+		for _, streamID := range r.Form["s"] {
+
+			var feedID uuid.UUID
+			var err error
+			switch action {
+			case "subscribe":
+				feedID, err = greaderSubscribe(s, r.Context(), user, streamID, title)
+			case "unsubscribe", "edit":
+				feedID, _, err = resolveGReaderFeed(s, r.Context(), streamID)
+			default:
+				http.Error(w, "unknown ac", http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				writeDBError(w, err)
+				return
+			}
+
+			if action == "unsubscribe" {
+				err = s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{UserID: user.ID, FeedID: feedID})
+				if err != nil {
+					writeDBError(w, err)
+					return
+				}
+				continue
+			}
+
+			if action == "edit" && title != "" {
+				_, err = s.db.SetFeedFollowDisplayName(r.Context(), database.SetFeedFollowDisplayNameParams{
+					UserID: user.ID,
+					FeedID: feedID,
+					DisplayName: sql.NullString{String: title, Valid: true},
+				})
+				if err != nil {
+					writeDBError(w, err)
+					return
+				}
+			}
+
+			for _, label := range r.Form["a"] {
+				tag, ok := strings.CutPrefix(normalizeStreamID(label), greaderLabelPrefix)
+				if !ok || tag == "" {
+					continue
+				}
+				err = s.db.AddFeedTag(r.Context(), database.AddFeedTagParams{
+					ID: uuid.New(),
+					CreatedAt: time.Now(),
+					UserID: user.ID,
+					FeedID: feedID,
+					Name: tag,
+				})
+				if err != nil {
+					writeDBError(w, err)
+					return
+				}
+			}
+			for _, label := range r.Form["r"] {
+				tag, ok := strings.CutPrefix(normalizeStreamID(label), greaderLabelPrefix)
+				if !ok {
+					continue
+				}
+				err = s.db.RemoveFeedTag(r.Context(), database.RemoveFeedTagParams{UserID: user.ID, FeedID: feedID, Name: tag})
+				if err != nil {
+					writeDBError(w, err)
+					return
+				}
+			}
+		}
+		writeOK(w)
+	}
+}
+
+func greaderQuickAdd(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		query := strings.TrimPrefix(r.Form.Get("quickadd"), greaderFeedPrefix)
+		if !strings.Contains(query, "://") {
+			http.Error(w, "quickadd must be a feed url", http.StatusBadRequest)
+			return
+		}
+
+		feedID, err := greaderSubscribe(s, r.Context(), user, greaderFeedPrefix+query, "")
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		feed, err := s.db.GetFeedByID(r.Context(), feedID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{
+			"numResults": 1,
+			"query": query,
+			"streamId": greaderFeedID(feed.Seq),
+			"streamName": feed.Name,
+		})
+	}
+}
+
+func greaderTagList(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		tags, err := s.db.GetFeedTagsForUser(r.Context(), user.ID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		var names []string
+		for _, v := range tags {
+			names = append(names, v.Name)
+		}
+
+		list := []greaderTag{{ID: greaderStarred}}
+		for _, v := range uniqueTags(names) {
+			list = append(list, greaderTag{ID: greaderLabelPrefix + v, Type: "folder"})
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"tags": list})
+	}
+}
+
+func greaderUnreadCounts(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		counts, err := s.db.GetGReaderUnreadCounts(r.Context(), user.ID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		labels, _, err := greaderLabels(s, r.Context(), user)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		var total int64
+		var newest time.Time
+		var order []string
+		labelCounts := map[string]int64{}
+		labelNewest := map[string]time.Time{}
+		unread := []greaderUnreadCount{}
+		for _, v := range counts {
+			unread = append(unread, greaderUnreadCount{ID: greaderFeedID(v.Seq), Count: v.Count, NewestItemTimestampUsec: usec(v.Newest)})
+			total += v.Count
+			if v.Newest.After(newest) {
+				newest = v.Newest
+			}
+
+			for _, tag := range labels[v.Seq] {
+				if _, ok := labelCounts[tag]; !ok {
+					order = append(order, tag)
+				}
+				labelCounts[tag] += v.Count
+				if v.Newest.After(labelNewest[tag]) {
+					labelNewest[tag] = v.Newest
+				}
+			}
+		}
+		for _, tag := range order {
+			unread = append(unread, greaderUnreadCount{ID: greaderLabelPrefix + tag, Count: labelCounts[tag], NewestItemTimestampUsec: usec(labelNewest[tag])})
+		}
+		unread = append(unread, greaderUnreadCount{ID: greaderReadingList, Count: total, NewestItemTimestampUsec: usec(newest)})
+
+		writeJSON(w, http.StatusOK, map[string]any{"max": greaderMaxCount, "unreadcounts": unread})
+	}
+}
+
+// greaderItems runs a stream query from the usual parameters: n (count),
+// c (continuation), xt/it (exclude/include a state), ot/nt (newer/older than,
+// in seconds) and r=o for oldest first. It returns the continuation for the
+// next page, empty on the last one
+func greaderItems(s *state, r *http.Request, user database.User, streamID string) ([]database.GetGReaderItemsRow, string, error) {
+
+	limit := greaderDefaultCount
+	if n, err := strconv.Atoi(r.Form.Get("n")); err == nil && n > 0 {
+		limit = min(n, greaderMaxCount)
+	}
+	offset, _ := strconv.Atoi(r.Form.Get("c"))
+	offset = max(offset, 0)
+
+	params := database.GetGReaderItemsParams{
+		UserID: user.ID,
+		OldestFirst: r.Form.Get("r") == "o",
+		Limit: int32(limit + 1),
+		Offset: int32(offset),
+	}
+	if !greaderStream(&params, streamID) {
+		return nil, "", errGReaderStream
+	}
+
+	switch normalizeStreamID(r.Form.Get("xt")) {
+	case greaderRead:
+		params.Read = sql.NullBool{Bool: false, Valid: true}
+	case greaderStarred:
+		params.Starred = sql.NullBool{Bool: false, Valid: true}
+	}
+	switch normalizeStreamID(r.Form.Get("it")) {
+	case greaderRead:
+		params.Read = sql.NullBool{Bool: true, Valid: true}
+	case greaderStarred:
+		params.Starred = sql.NullBool{Bool: true, Valid: true}
+	}
+	if ot, err := strconv.ParseInt(r.Form.Get("ot"), 10, 64); err == nil {
+		params.NewerThan = sql.NullTime{Time: time.Unix(ot, 0), Valid: true}
+	}
+	if nt, err := strconv.ParseInt(r.Form.Get("nt"), 10, 64); err == nil {
+		params.OlderThan = sql.NullTime{Time: time.Unix(nt, 0), Valid: true}
+	}
+
+	items, err := s.db.GetGReaderItems(r.Context(), params)
+	if err != nil {
+		return nil, "", err
+	}
+
+	continuation := ""
+	if len(items) > limit {
+		items = items[:limit]
+		continuation = strconv.Itoa(offset + limit)
+	}
+	return items, continuation, nil
+}
+
+var errGReaderStream = errors.New("unknown stream")
+
+func writeGReaderError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errGReaderStream) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeDBError(w, err)
+}
+
+func greaderItemIDs(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		items, continuation, err := greaderItems(s, r, user, r.Form.Get("s"))
+		if err != nil {
+			writeGReaderError(w, err)
+			return
+		}
+
+		refs := []greaderItemRef{}
+		for _, v := range items {
+			refs = append(refs, greaderItemRef{
+				ID: strconv.FormatInt(v.Seq, 10),
+				DirectStreamIDs: []string{greaderFeedID(v.FeedSeq)},
+				TimestampUsec: usec(v.PublishedAt),
+			})
+		}
+
+		resp := map[string]any{"itemRefs": refs}
+		if continuation != "" {
+			resp["continuation"] = continuation
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func newGReaderItem(v database.GetGReaderItemsRow, labels map[int64][]string) greaderItem {
+
+	categories := []string{greaderReadingList}
+	if v.ReadAt.Valid {
+		categories = append(categories, greaderRead)
+	}
+	if v.StarredAt.Valid {
+		categories = append(categories, greaderStarred)
+	}
+	for _, tag := range labels[v.FeedSeq] {
+		categories = append(categories, greaderLabelPrefix+tag)
+	}
+
+	return greaderItem{
+		ID: greaderItemID(v.Seq),
+		CrawlTimeMsec: strconv.FormatInt(v.CreatedAt.UnixMilli(), 10),
+		TimestampUsec: usec(v.PublishedAt),
+		Published: v.PublishedAt.Unix(),
+		Updated: v.PublishedAt.Unix(),
+		Title: v.Title,
+		Canonical: []greaderLink{{Href: v.Url}},
+		Alternate: []greaderLink{{Href: v.Url, Type: "text/html"}},
+		Summary: greaderContent{Direction: "ltr", Content: v.Description.String},
+		Categories: categories,
+		Origin: greaderOrigin{
+			StreamID: greaderFeedID(v.FeedSeq),
+			Title: v.FeedTitle,
+			HtmlUrl: v.FeedSiteUrl.String,
+		},
+	}
+}
+
+func writeGReaderContents(s *state, w http.ResponseWriter, r *http.Request, user database.User, streamID string, items []database.GetGReaderItemsRow, continuation string) {
+
+	labels, _, err := greaderLabels(s, r.Context(), user)
+	if err != nil {
+		writeDBError(w, err)
+		return
+	}
+
+	contents := greaderStreamContents{
+		Direction: "ltr",
+		ID: streamID,
+		Updated: time.Now().Unix(),
+		Items: []greaderItem{},
+		Continuation: continuation,
+	}
+	for _, v := range items {
+		contents.Items = append(contents.Items, newGReaderItem(v, labels))
+	}
+	writeJSON(w, http.StatusOK, contents)
+}
+
+func greaderStreamContentsHandler(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		streamID := r.PathValue("stream")
+		if streamID == "" {
+			streamID = r.Form.Get("s")
+		}
+		if streamID == "" {
+			streamID = greaderReadingList
+		}
+
+		items, continuation, err := greaderItems(s, r, user, streamID)
+		if err != nil {
+			writeGReaderError(w, err)
+			return
+		}
+		writeGReaderContents(s, w, r, user, streamID, items, continuation)
+	}
+}
+
+// greaderItemContents returns the items listed in i, however many there are
+func greaderItemContents(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		ids := []int64{}
+		for _, v := range r.Form["i"] {
+			if seq, ok := parseGReaderItemID(v); ok {
+				ids = append(ids, seq)
+			}
+		}
+
+		items, err := s.db.GetGReaderItems(r.Context(), database.GetGReaderItemsParams{
+			UserID: user.ID,
+			WithIds: ids,
+			Limit: int32(len(ids)),
+		})
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		writeGReaderContents(s, w, r, user, greaderReadingList, items, "")
+	}
+}
+
+// greaderEditTag adds (a) or removes (r) the read and starred states on the
+// items in i. Removing kept-unread is the same as marking read
+func greaderEditTag(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		var read, starred sql.NullBool
+		for _, v := range r.Form["a"] {
+			switch normalizeStreamID(v) {
+			case greaderRead:
+				read = sql.NullBool{Bool: true, Valid: true}
+			case greaderKeptUnread:
+				read = sql.NullBool{Bool: false, Valid: true}
+			case greaderStarred:
+				starred = sql.NullBool{Bool: true, Valid: true}
+			}
+		}
+		for _, v := range r.Form["r"] {
+			switch normalizeStreamID(v) {
+			case greaderRead:
+				read = sql.NullBool{Bool: false, Valid: true}
+			case greaderKeptUnread:
+				read = sql.NullBool{Bool: true, Valid: true}
+			case greaderStarred:
+				starred = sql.NullBool{Bool: false, Valid: true}
+			}
+		}
+
+		now := sql.NullTime{Time: time.Now(), Valid: true}
+		for _, v := range r.Form["i"] {
+			seq, ok := parseGReaderItemID(v)
+			if !ok {
+				continue
+			}
+			postID, err := s.db.GetPostIDBySeq(r.Context(), database.GetPostIDBySeqParams{UserID: user.ID, Seq: seq})
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				writeDBError(w, err)
+				return
+			}
+
+			if read.Valid {
+				params := database.SetPostReadParams{UserID: user.ID, PostID: postID}
+				if read.Bool {
+					params.ReadAt = now
+				}
+				err = s.db.SetPostRead(r.Context(), params)
+				if err != nil {
+					writeDBError(w, err)
+					return
+				}
+			}
+			if starred.Valid {
+				params := database.SetPostStarredParams{UserID: user.ID, PostID: postID}
+				if starred.Bool {
+					params.StarredAt = now
+				}
+				err = s.db.SetPostStarred(r.Context(), params)
+				if err != nil {
+					writeDBError(w, err)
+					return
+				}
+			}
+		}
+		writeOK(w)
+	}
+}
+
+// greaderMarkAllAsRead marks a feed, label or the whole reading list read, up
+// to ts (microseconds) when the client sends it
+func greaderMarkAllAsRead(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		var stream database.GetGReaderItemsParams
+		if !greaderStream(&stream, r.Form.Get("s")) {
+			http.Error(w, errGReaderStream.Error(), http.StatusBadRequest)
+			return
+		}
+		// only feeds, labels and the reading list can be marked read wholesale
+		if stream.Starred.Valid || stream.Read.Valid {
+			writeOK(w)
+			return
+		}
+
+		params := database.MarkPostsReadBeforeParams{
+			ReadAt: time.Now(),
+			UserID: user.ID,
+			Before: time.Now(),
+			FeedSeq: stream.FeedSeq,
+			Tag: stream.Tag,
+		}
+		if ts, err := strconv.ParseInt(r.Form.Get("ts"), 10, 64); err == nil && ts > 0 {
+			params.Before = time.UnixMicro(ts)
+		}
+
+		err := s.db.MarkPostsReadBefore(r.Context(), params)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		writeOK(w)
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/term"
+)
+
+const (
+	defaultTextWidth = 80
+
+	ansiItalic = "\x1b[3m"
+)
+
+// stripControl drops C0 and C1 control characters but newlines and tabs, so
+// feed text like "&#27;[2J" can't drive the terminal it is printed to
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < ' ' || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// stdoutIsTerminal decides whether rendered text gets ANSI styling
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+func terminalWidth() int {
+	w, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || w <= 0 {
+		return defaultTextWidth
+	}
+	return w
+}
+
+type textSegment struct {
+	text  string
+	style string
+}
+
+type textWord []textSegment
+
+func (w textWord) width() int {
+	n := 0
+	for _, s := range w {
+		n += utf8.RuneCountInString(s.text)
+	}
+	return n
+}
+
+func (w textWord) render(ansi bool) string {
+	var b strings.Builder
+	for _, s := range w {
+		if ansi && s.style != "" {
+			b.WriteString(s.style + s.text + ansiReset)
+		} else {
+			b.WriteString(s.text)
+		}
+	}
+	return b.String()
+}
+
+type htmlList struct {
+	ordered bool
+	count   int
+}
+
+// htmlRenderer turns post descriptions into wrapped plain text. Block
+// elements start new paragraphs, links are numbered and listed at the end
+type htmlRenderer struct {
+	width int
+	ansi  bool
+
+	out       strings.Builder
+	words     []textWord
+	glue      bool
+	prefixes  []string
+	bullet    string
+	lists     []htmlList
+	links     []string
+	bold      int
+	italic    int
+	code      int
+	pre       int
+	preText   strings.Builder
+	needBlank bool
+	wroteAny  bool
+}
+
+func renderHTML(src string, width int, ansi bool) string {
+
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return src
+	}
+
+	r := &htmlRenderer{width: max(width, 20), ansi: ansi}
+	r.walk(doc)
+	r.flush()
+
+	if len(r.links) > 0 {
+		r.blank()
+		r.writeLine("Links:")
+		for i, v := range r.links {
+			r.writeLine(fmt.Sprintf("[%d] %s", i+1, v))
+		}
+	}
+
+	return strings.TrimRight(r.out.String(), "\n")
+}
+
+func (r *htmlRenderer) style() string {
+	style := ""
+	if r.bold > 0 {
+		style += ansiBold
+	}
+	if r.italic > 0 {
+		style += ansiItalic
+	}
+	if r.code > 0 {
+		style += ansiDim
+	}
+	return style
+}
+
+func (r *htmlRenderer) addText(text string) {
+
+	text = stripControl(text)
+	if r.pre > 0 {
+		r.preText.WriteString(text)
+		return
+	}
+
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		if text != "" {
+			r.glue = false
+		}
+		return
+	}
+
+	first, _ := utf8.DecodeRuneInString(text)
+	last, _ := utf8.DecodeLastRuneInString(text)
+	for i, v := range words {
+		segment := textSegment{text: v, style: r.style()}
+		if i == 0 && r.glue && !unicode.IsSpace(first) && len(r.words) > 0 {
+			r.words[len(r.words)-1] = append(r.words[len(r.words)-1], segment)
+			continue
+		}
+		r.words = append(r.words, textWord{segment})
+	}
+	r.glue = !unicode.IsSpace(last)
+}
+
+// addInline glues a marker like a footnote number onto the previous word
+func (r *htmlRenderer) addInline(text string) {
+	segment := textSegment{text: text}
+	if len(r.words) > 0 {
+		r.words[len(r.words)-1] = append(r.words[len(r.words)-1], segment)
+	} else {
+		r.words = append(r.words, textWord{segment})
+	}
+	r.glue = true
+}
+
+// writePre emits a preformatted block line by line, indented and unwrapped
+func (r *htmlRenderer) writePre() {
+	text := strings.Trim(r.preText.String(), "\n")
+	r.preText.Reset()
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		if r.ansi && line != "" {
+			line = ansiDim + line + ansiReset
+		}
+		r.writeLine(r.prefix() + "    " + line)
+	}
+}
+
+func (r *htmlRenderer) prefix() string {
+	return strings.Join(r.prefixes, "")
+}
+
+func (r *htmlRenderer) writeLine(line string) {
+	r.separate()
+	r.wroteAny = true
+	r.out.WriteString(strings.TrimRight(line, " ") + "\n")
+}
+
+// flush wraps the words collected so far into lines under the current prefix
+func (r *htmlRenderer) flush() {
+
+	r.glue = false
+	if len(r.words) == 0 {
+		return
+	}
+
+	prefix := r.prefix()
+	first := prefix
+	if r.bullet != "" {
+		first = prefix + r.bullet
+		prefix += strings.Repeat(" ", utf8.RuneCountInString(r.bullet))
+		r.bullet = ""
+	}
+
+	available := max(r.width-utf8.RuneCountInString(prefix), 10)
+	current := first
+	lineWidth := 0
+	for _, w := range r.words {
+		if lineWidth > 0 && lineWidth+1+w.width() > available {
+			r.writeLine(current)
+			current = prefix
+			lineWidth = 0
+		}
+		if lineWidth > 0 {
+			current += " "
+			lineWidth++
+		}
+		current += w.render(r.ansi)
+		lineWidth += w.width()
+	}
+	r.writeLine(current)
+	r.words = nil
+}
+
+// separate writes a pending paragraph break right away, before the prefix changes
+func (r *htmlRenderer) separate() {
+	if r.needBlank && r.wroteAny {
+		r.out.WriteString(strings.TrimRight(r.prefix(), " ") + "\n")
+	}
+	r.needBlank = false
+}
+
+func (r *htmlRenderer) blank() {
+	r.flush()
+	r.needBlank = true
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func (r *htmlRenderer) addLink(href string) {
+	href = stripControl(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+	for i, v := range r.links {
+		if v == href {
+			r.addInline(fmt.Sprintf("[%d]", i+1))
+			return
+		}
+	}
+	r.links = append(r.links, href)
+	r.addInline(fmt.Sprintf("[%d]", len(r.links)))
+}
+
+func (r *htmlRenderer) walkChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+func (r *htmlRenderer) walk(n *html.Node) {
+
+	switch n.Type {
+	case html.TextNode:
+		r.addText(n.Data)
+		return
+	case html.DocumentNode:
+		r.walkChildren(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Noscript, atom.Iframe:
+		return
+	case atom.Br:
+		r.flush()
+	case atom.Hr:
+		r.blank()
+		r.writeLine(r.prefix() + strings.Repeat("─", min(r.width, 40)))
+		r.needBlank = true
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Figure, atom.Table, atom.Dl:
+		r.blank()
+		r.walkChildren(n)
+		r.blank()
+	case atom.Tr, atom.Dt, atom.Dd, atom.Figcaption:
+		r.flush()
+		r.walkChildren(n)
+		r.flush()
+	case atom.Td, atom.Th:
+		r.walkChildren(n)
+		r.glue = false
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		r.blank()
+		r.bold++
+		r.walkChildren(n)
+		r.bold--
+		r.blank()
+	case atom.B, atom.Strong:
+		r.bold++
+		r.walkChildren(n)
+		r.bold--
+	case atom.I, atom.Em, atom.Cite:
+		r.italic++
+		r.walkChildren(n)
+		r.italic--
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		if r.pre > 0 || r.ansi {
+			r.code++
+			r.walkChildren(n)
+			r.code--
+			return
+		}
+		r.addText("`")
+		r.glue = true
+		r.walkChildren(n)
+		r.addInline("`")
+	case atom.Pre:
+		r.blank()
+		r.pre++
+		r.walkChildren(n)
+		r.pre--
+		if r.pre == 0 {
+			r.writePre()
+		}
+		r.needBlank = true
+	case atom.Blockquote:
+		r.blank()
+		r.separate()
+		r.prefixes = append(r.prefixes, "│ ")
+		r.walkChildren(n)
+		r.flush()
+		r.prefixes = r.prefixes[:len(r.prefixes)-1]
+		r.needBlank = true
+	case atom.Ul, atom.Ol:
+		if len(r.lists) == 0 {
+			r.blank()
+		} else {
+			r.flush()
+		}
+		r.lists = append(r.lists, htmlList{ordered: n.DataAtom == atom.Ol})
+		r.prefixes = append(r.prefixes, "  ")
+		r.walkChildren(n)
+		r.flush()
+		r.prefixes = r.prefixes[:len(r.prefixes)-1]
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.needBlank = true
+		}
+	case atom.Li:
+		r.flush()
+		if len(r.lists) > 0 {
+			list := &r.lists[len(r.lists)-1]
+			list.count++
+			if list.ordered {
+				r.bullet = fmt.Sprintf("%d. ", list.count)
+			} else {
+				r.bullet = "• "
+			}
+		}
+		r.walkChildren(n)
+		r.flush()
+	case atom.A:
+		r.walkChildren(n)
+		r.addLink(attr(n, "href"))
+	case atom.Img:
+		alt := attr(n, "alt")
+		if alt == "" {
+			alt = "image"
+		}
+		r.addText(" [" + alt + "]")
+		r.addLink(attr(n, "src"))
+		r.glue = false
+	default:
+		r.walkChildren(n)
+	}
+}
@@ -29,7 +29,13 @@ func write(cfg Config) error {
 		return err
 	}
 	
-	err = os.WriteFile(configFilePath, jsonData, 0644)
+	// the session token lives here, so keep it private to the user
+	err = os.WriteFile(configFilePath, jsonData, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(configFilePath, 0600)
 	if err != nil {
 		return err
 	}
@@ -39,8 +45,9 @@ func write(cfg Config) error {
 // ======== Exports ======== 
 
 type Config struct {
-	DBURL           string `json:"db_url"`
-	CurrentUserName string `json:"current_user_name"`
+	DBURL            string `json:"db_url"`
+	CurrentUserName  string `json:"current_user_name"`
+	CurrentUserToken string `json:"current_user_token,omitempty"`
 }
 
 
@@ -67,9 +74,10 @@ func Read() (Config, error) {
 }
 
 
-func (cfg *Config) SetUser(newUser string) error {		
+func (cfg *Config) SetUser(newUser, token string) error {		
 
 	cfg.CurrentUserName = newUser
+	cfg.CurrentUserToken = token
 
 	err := write(*cfg)
 	if err != nil {
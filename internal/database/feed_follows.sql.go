@@ -23,10 +23,10 @@ WITH inserted_feed_follow AS (
 	$4,
 	$5
     )
-    RETURNING id, created_at, updated_at, feed_id, user_id
+    RETURNING id, created_at, updated_at, feed_id, user_id, display_name
 )
 
-SELECT ff.id, ff.created_at, ff.updated_at, ff.feed_id, ff.user_id, u.name AS user_name, f.name AS feed_name
+SELECT ff.id, ff.created_at, ff.updated_at, ff.feed_id, ff.user_id, ff.display_name, u.name AS user_name, f.name AS feed_name
 FROM inserted_feed_follow ff
     JOIN users u ON u.id = ff.user_id
     JOIN feeds f ON f.id = ff.feed_id
@@ -41,13 +41,14 @@ type CreateFeedFollowParams struct {
 }
 
 type CreateFeedFollowRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	FeedID    uuid.UUID
-	UserID    uuid.UUID
-	UserName  sql.NullString
-	FeedName  string
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FeedID      uuid.UUID
+	UserID      uuid.UUID
+	DisplayName sql.NullString
+	UserName    sql.NullString
+	FeedName    string
 }
 
 func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (CreateFeedFollowRow, error) {
@@ -65,6 +66,7 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 		&i.UpdatedAt,
 		&i.FeedID,
 		&i.UserID,
+		&i.DisplayName,
 		&i.UserName,
 		&i.FeedName,
 	)
@@ -87,7 +89,8 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 }
 
 const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT ff.id, ff.created_at, ff.updated_at, ff.feed_id, ff.user_id, u.name AS user_name, f.name AS feed_name
+SELECT ff.id, ff.created_at, ff.updated_at, ff.feed_id, ff.user_id, ff.display_name, u.name AS user_name, f.name AS feed_name, f.url AS feed_url, f.site_url AS feed_site_url,
+    COALESCE(ff.display_name, f.name)::TEXT AS feed_title
 FROM feed_follows ff
     JOIN users u ON u.id = ff.user_id
     JOIN feeds f ON f.id = ff.feed_id
@@ -95,13 +98,17 @@ WHERE ff.user_id = $1
 `
 
 type GetFeedFollowsForUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	FeedID    uuid.UUID
-	UserID    uuid.UUID
-	UserName  sql.NullString
-	FeedName  string
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FeedID      uuid.UUID
+	UserID      uuid.UUID
+	DisplayName sql.NullString
+	UserName    sql.NullString
+	FeedName    string
+	FeedUrl     sql.NullString
+	FeedSiteUrl sql.NullString
+	FeedTitle   string
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
@@ -119,8 +126,12 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.FeedID,
 			&i.UserID,
+			&i.DisplayName,
 			&i.UserName,
 			&i.FeedName,
+			&i.FeedUrl,
+			&i.FeedSiteUrl,
+			&i.FeedTitle,
 		); err != nil {
 			return nil, err
 		}
@@ -134,3 +145,43 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const isFollowingFeed = `-- name: IsFollowingFeed :one
+SELECT EXISTS (
+    SELECT 1
+    FROM feed_follows
+    WHERE user_id = $1 AND feed_id = $2
+)
+`
+
+type IsFollowingFeedParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) IsFollowingFeed(ctx context.Context, arg IsFollowingFeedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isFollowingFeed, arg.UserID, arg.FeedID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const setFeedFollowDisplayName = `-- name: SetFeedFollowDisplayName :execrows
+UPDATE feed_follows
+SET display_name = $3, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type SetFeedFollowDisplayNameParams struct {
+	UserID      uuid.UUID
+	FeedID      uuid.UUID
+	DisplayName sql.NullString
+}
+
+func (q *Queries) SetFeedFollowDisplayName(ctx context.Context, arg SetFeedFollowDisplayNameParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setFeedFollowDisplayName, arg.UserID, arg.FeedID, arg.DisplayName)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
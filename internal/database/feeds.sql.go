@@ -23,7 +23,8 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+ON CONFLICT (url) DO NOTHING
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, site_url, seq
 `
 
 type CreateFeedParams struct {
@@ -53,25 +54,108 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.SiteUrl,
+		&i.Seq,
 	)
 	return i, err
 }
 
-const getFeedIdByURL = `-- name: GetFeedIdByURL :one
-SELECT id
-FROM  feeds
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const deleteFeeds = `-- name: DeleteFeeds :execrows
+DELETE FROM feeds
+`
+
+func (q *Queries) DeleteFeeds(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeeds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOrphanedFeeds = `-- name: DeleteOrphanedFeeds :execrows
+DELETE FROM feeds f
+WHERE NOT EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id)
+`
+
+func (q *Queries) DeleteOrphanedFeeds(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOrphanedFeeds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getFeedByID = `-- name: GetFeedByID :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, site_url, seq
+FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) GetFeedByID(ctx context.Context, id uuid.UUID) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByID, id)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.SiteUrl,
+		&i.Seq,
+	)
+	return i, err
+}
+
+const getFeedByURL = `-- name: GetFeedByURL :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, site_url, seq
+FROM feeds
 WHERE url = $1
 `
 
-func (q *Queries) GetFeedIdByURL(ctx context.Context, url sql.NullString) (uuid.UUID, error) {
-	row := q.db.QueryRowContext(ctx, getFeedIdByURL, url)
+func (q *Queries) GetFeedByURL(ctx context.Context, url sql.NullString) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.SiteUrl,
+		&i.Seq,
+	)
+	return i, err
+}
+
+const getFeedIDBySeq = `-- name: GetFeedIDBySeq :one
+SELECT id
+FROM feeds
+WHERE seq = $1
+`
+
+func (q *Queries) GetFeedIDBySeq(ctx context.Context, seq int64) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getFeedIDBySeq, seq)
 	var id uuid.UUID
 	err := row.Scan(&id)
 	return id, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, site_url, seq
 FROM feeds
 `
 
@@ -92,6 +176,8 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.SiteUrl,
+			&i.Seq,
 		); err != nil {
 			return nil, err
 		}
@@ -107,8 +193,9 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at 
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, site_url, seq 
 FROM feeds
+WHERE EXISTS (SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id)
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT 1
 `
@@ -124,10 +211,54 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.SiteUrl,
+		&i.Seq,
 	)
 	return i, err
 }
 
+const getOrphanedFeeds = `-- name: GetOrphanedFeeds :many
+SELECT f.id, f.name, f.url, (SELECT COUNT(*) FROM posts p WHERE p.feed_id = f.id) AS post_count
+FROM feeds f
+WHERE NOT EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id)
+ORDER BY f.name
+`
+
+type GetOrphanedFeedsRow struct {
+	ID        uuid.UUID
+	Name      string
+	Url       sql.NullString
+	PostCount int64
+}
+
+func (q *Queries) GetOrphanedFeeds(ctx context.Context) ([]GetOrphanedFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOrphanedFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetOrphanedFeedsRow
+	for rows.Next() {
+		var i GetOrphanedFeedsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.PostCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const markedFeedFetched = `-- name: MarkedFeedFetched :exec
 UPDATE feeds
 SET updated_at = CURRENT_TIMESTAMP, last_fetched_at = CURRENT_TIMESTAMP
@@ -138,3 +269,55 @@ func (q *Queries) MarkedFeedFetched(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, markedFeedFetched, id)
 	return err
 }
+
+const reassignFeeds = `-- name: ReassignFeeds :execrows
+UPDATE feeds
+SET user_id = $1
+WHERE user_id = $2
+`
+
+type ReassignFeedsParams struct {
+	NewUserID uuid.NullUUID
+	OldUserID uuid.NullUUID
+}
+
+func (q *Queries) ReassignFeeds(ctx context.Context, arg ReassignFeedsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, reassignFeeds, arg.NewUserID, arg.OldUserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setFeedSiteURL = `-- name: SetFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2
+WHERE id = $1
+`
+
+type SetFeedSiteURLParams struct {
+	ID      uuid.UUID
+	SiteUrl sql.NullString
+}
+
+func (q *Queries) SetFeedSiteURL(ctx context.Context, arg SetFeedSiteURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedSiteURL, arg.ID, arg.SiteUrl)
+	return err
+}
+
+const setFeedURL = `-- name: SetFeedURL :exec
+UPDATE feeds
+SET url = $2, updated_at = $3
+WHERE id = $1
+`
+
+type SetFeedURLParams struct {
+	ID        uuid.UUID
+	Url       sql.NullString
+	UpdatedAt time.Time
+}
+
+func (q *Queries) SetFeedURL(ctx context.Context, arg SetFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedURL, arg.ID, arg.Url, arg.UpdatedAt)
+	return err
+}
@@ -11,6 +11,16 @@ import (
 	"github.com/google/uuid"
 )
 
+type ApiToken struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	TokenHash    string
+	LastUsedAt   sql.NullTime
+	FeverKeyHash sql.NullString
+}
+
 type Feed struct {
 	ID            uuid.UUID
 	CreatedAt     time.Time
@@ -19,14 +29,49 @@ type Feed struct {
 	Url           sql.NullString
 	UserID        uuid.NullUUID
 	LastFetchedAt sql.NullTime
+	SiteUrl       sql.NullString
+	Seq           int64
 }
 
 type FeedFollow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FeedID      uuid.UUID
+	UserID      uuid.UUID
+	DisplayName sql.NullString
+}
+
+type FeedIcon struct {
+	FeedID    uuid.UUID
+	FetchedAt time.Time
+	MimeType  sql.NullString
+	Data      []byte
+}
+
+type FeedRetention struct {
+	FeedID    uuid.UUID
+	UpdatedAt time.Time
+	KeepPosts sql.NullInt32
+	KeepDays  sql.NullInt32
+}
+
+type FeedTag struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	FeedID    uuid.UUID
 	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	Name      string
+}
+
+type FilterRule struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	FeedID     uuid.NullUUID
+	TitleRegex sql.NullString
+	Keyword    sql.NullString
+	Action     string
 }
 
 type Post struct {
@@ -38,11 +83,46 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Seq         int64
+	Guid        string
+	ContentHash string
 }
 
-type User struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
+type PostRevision struct {
+	ID          uuid.UUID
+	PostID      uuid.UUID
+	ReplacedAt  time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	ContentHash string
+}
+
+type PostState struct {
+	UserID    uuid.UUID
+	PostID    uuid.UUID
+	ReadAt    sql.NullTime
+	StarredAt sql.NullTime
+}
+
+type PrunedPost struct {
+	FeedID   uuid.UUID
+	Guid     string
+	PrunedAt time.Time
+}
+
+type RetentionDefault struct {
+	ID        bool
 	UpdatedAt time.Time
-	Name      sql.NullString
+	KeepPosts int32
+	KeepDays  int32
+}
+
+type User struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         sql.NullString
+	PasswordHash sql.NullString
+	IsAdmin      bool
 }
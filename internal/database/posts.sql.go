@@ -11,86 +11,309 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts(id, created_at, updated_at, title, url, description, published_at, feed_id) 
-VALUES(
-    $1, 
-    $2, 
-    $3,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8
+const adoptPostGUIDs = `-- name: AdoptPostGUIDs :exec
+UPDATE posts
+SET guid = i.guid
+FROM unnest($1::TEXT[], $2::TEXT[]) AS i(guid, link_key)
+WHERE posts.feed_id = $3 AND posts.guid = i.link_key
+    AND NOT EXISTS (
+	SELECT 1 FROM posts o
+	WHERE o.feed_id = $3 AND o.guid = i.guid
+    )
+`
+
+type AdoptPostGUIDsParams struct {
+	Guids    []string
+	LinkKeys []string
+	FeedID   uuid.UUID
+}
+
+func (q *Queries) AdoptPostGUIDs(ctx context.Context, arg AdoptPostGUIDsParams) error {
+	_, err := q.db.ExecContext(ctx, adoptPostGUIDs, pq.Array(arg.Guids), pq.Array(arg.LinkKeys), arg.FeedID)
+	return err
+}
+
+const createPosts = `-- name: CreatePosts :execrows
+INSERT INTO posts(id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash)
+SELECT i.id, $1::TIMESTAMP, $1::TIMESTAMP, i.title, i.url, NULLIF(i.description, ''),
+    i.published_at::TIMESTAMP, $2::UUID, i.guid, i.content_hash
+FROM unnest(
+    $3::UUID[],
+    $4::TEXT[],
+    $5::TEXT[],
+    $6::TEXT[],
+    $7::TEXT[],
+    $8::TEXT[],
+    $9::TEXT[]
+) AS i(id, title, url, description, published_at, guid, content_hash)
+WHERE NOT EXISTS (
+    SELECT 1 FROM pruned_posts pp
+    WHERE pp.feed_id = $2 AND pp.guid = i.guid
 )
-ON CONFLICT (url) DO NOTHING
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+ON CONFLICT (feed_id, guid) DO NOTHING
 `
 
-type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+type CreatePostsParams struct {
+	CreatedAt     time.Time
+	FeedID        uuid.UUID
+	Ids           []uuid.UUID
+	Titles        []string
+	Urls          []string
+	Descriptions  []string
+	PublishedAts  []string
+	Guids         []string
+	ContentHashes []string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
+func (q *Queries) CreatePosts(ctx context.Context, arg CreatePostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createPosts,
 		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
 		arg.FeedID,
+		pq.Array(arg.Ids),
+		pq.Array(arg.Titles),
+		pq.Array(arg.Urls),
+		pq.Array(arg.Descriptions),
+		pq.Array(arg.PublishedAts),
+		pq.Array(arg.Guids),
+		pq.Array(arg.ContentHashes),
 	)
-	var i Post
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Title,
-		&i.Url,
-		&i.Description,
-		&i.PublishedAt,
-		&i.FeedID,
-	)
-	return i, err
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id
+const getDuplicatePostFeeds = `-- name: GetDuplicatePostFeeds :many
+SELECT COALESCE(ff.display_name, f.name)::TEXT AS feed_title
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+WHERE ff.user_id = $1 AND p.url = $2 AND p.id <> $3
+ORDER BY feed_title
+`
+
+type GetDuplicatePostFeedsParams struct {
+	UserID uuid.UUID
+	Url    string
+	ID     uuid.UUID
+}
+
+func (q *Queries) GetDuplicatePostFeeds(ctx context.Context, arg GetDuplicatePostFeedsParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getDuplicatePostFeeds, arg.UserID, arg.Url, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var feed_title string
+		if err := rows.Scan(&feed_title); err != nil {
+			return nil, err
+		}
+		items = append(items, feed_title)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostHashesByGUID = `-- name: GetPostHashesByGUID :many
+SELECT id, guid, content_hash
 FROM posts
-WHERE feed_id IN (
-    SELECT feed_id
-    FROM feed_follows
-    WHERE user_id = $1
-)
-ORDER BY published_at DESC
-LIMIT $2
+WHERE feed_id = $1 AND guid = ANY($2::TEXT[])
 `
 
-type GetPostsForUserParams struct {
+type GetPostHashesByGUIDParams struct {
+	FeedID uuid.UUID
+	Guids  []string
+}
+
+type GetPostHashesByGUIDRow struct {
+	ID          uuid.UUID
+	Guid        string
+	ContentHash string
+}
+
+func (q *Queries) GetPostHashesByGUID(ctx context.Context, arg GetPostHashesByGUIDParams) ([]GetPostHashesByGUIDRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostHashesByGUID, arg.FeedID, pq.Array(arg.Guids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostHashesByGUIDRow
+	for rows.Next() {
+		var i GetPostHashesByGUIDRow
+		if err := rows.Scan(&i.ID, &i.Guid, &i.ContentHash); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostIDBySeq = `-- name: GetPostIDBySeq :one
+SELECT p.id
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+WHERE ff.user_id = $1 AND p.seq = $2
+`
+
+type GetPostIDBySeqParams struct {
 	UserID uuid.UUID
-	Limit  int32
+	Seq    int64
+}
+
+func (q *Queries) GetPostIDBySeq(ctx context.Context, arg GetPostIDBySeqParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getPostIDBySeq, arg.UserID, arg.Seq)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const getPostURLs = `-- name: GetPostURLs :many
+SELECT id, url
+FROM posts
+`
+
+type GetPostURLsRow struct {
+	ID  uuid.UUID
+	Url string
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+func (q *Queries) GetPostURLs(ctx context.Context) ([]GetPostURLsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostURLs)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostURLsRow
 	for rows.Next() {
-		var i Post
+		var i GetPostURLsRow
+		if err := rows.Scan(&i.ID, &i.Url); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+SELECT p.id, p.created_at, p.updated_at, p.title, p.url, p.description, p.published_at, p.feed_id, p.seq, p.guid, p.content_hash, COALESCE(ff.display_name, f.name)::TEXT AS feed_title, f.url AS feed_url,
+    ps.read_at, ps.starred_at,
+    EXISTS (
+	SELECT 1 FROM filter_rules r
+	WHERE r.user_id = ff.user_id AND r.action = 'highlight'
+	    AND (r.feed_id IS NULL OR r.feed_id = p.feed_id)
+	    AND (r.title_regex IS NULL OR p.title ~ r.title_regex)
+	    AND (r.keyword IS NULL OR strpos(lower(p.title || ' ' || COALESCE(p.description, '')), lower(r.keyword)) > 0)
+    ) AS highlighted
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+    LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1
+    AND ($2::UUID IS NULL OR p.feed_id = $2)
+    AND ($3::TEXT IS NULL OR EXISTS (
+	SELECT 1
+	FROM feed_tags t
+	WHERE t.user_id = ff.user_id AND t.feed_id = p.feed_id AND t.name = $3
+    ))
+    AND ($4::BOOLEAN IS NULL OR (ps.starred_at IS NOT NULL) = $4)
+    AND ($5::BOOLEAN IS NULL OR (ps.read_at IS NOT NULL) = $5)
+    AND ($6::TEXT IS NULL OR p.id::TEXT LIKE $6 || '%')
+    AND ($7::TEXT IS NULL OR p.url = $7)
+    AND ($8::TEXT IS NULL
+	OR strpos(lower(p.title), lower($8)) > 0
+	OR strpos(lower(p.description), lower($8)) > 0)
+    AND ($9::BOOLEAN IS NULL
+	OR (ps.read_at IS NOT NULL AND p.updated_at > ps.read_at) = $9)
+    AND ($10::BOOLEAN OR NOT EXISTS (
+	SELECT 1 FROM filter_rules r
+	WHERE r.user_id = ff.user_id AND r.action = 'hide'
+	    AND (r.feed_id IS NULL OR r.feed_id = p.feed_id)
+	    AND (r.title_regex IS NULL OR p.title ~ r.title_regex)
+	    AND (r.keyword IS NULL OR strpos(lower(p.title || ' ' || COALESCE(p.description, '')), lower(r.keyword)) > 0)
+    ))
+ORDER BY p.published_at DESC, p.seq DESC
+LIMIT $11
+OFFSET $12
+`
+
+type GetPostsForUserParams struct {
+	UserID     uuid.UUID
+	FeedID     uuid.NullUUID
+	Tag        sql.NullString
+	Starred    sql.NullBool
+	Read       sql.NullBool
+	IDPrefix   sql.NullString
+	Url        sql.NullString
+	Search     sql.NullString
+	Updated    sql.NullBool
+	ShowHidden bool
+	Limit      int32
+	Offset     int32
+}
+
+type GetPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Seq         int64
+	Guid        string
+	ContentHash string
+	FeedTitle   string
+	FeedUrl     sql.NullString
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+	Highlighted bool
+}
+
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.FeedID,
+		arg.Tag,
+		arg.Starred,
+		arg.Read,
+		arg.IDPrefix,
+		arg.Url,
+		arg.Search,
+		arg.Updated,
+		arg.ShowHidden,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsForUserRow
+	for rows.Next() {
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -100,6 +323,14 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Seq,
+			&i.Guid,
+			&i.ContentHash,
+			&i.FeedTitle,
+			&i.FeedUrl,
+			&i.ReadAt,
+			&i.StarredAt,
+			&i.Highlighted,
 		); err != nil {
 			return nil, err
 		}
@@ -113,3 +344,47 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const setPostURLs = `-- name: SetPostURLs :exec
+UPDATE posts
+SET url = i.url
+FROM unnest($1::UUID[], $2::TEXT[]) AS i(id, url)
+WHERE posts.id = i.id
+`
+
+type SetPostURLsParams struct {
+	Ids  []uuid.UUID
+	Urls []string
+}
+
+func (q *Queries) SetPostURLs(ctx context.Context, arg SetPostURLsParams) error {
+	_, err := q.db.ExecContext(ctx, setPostURLs, pq.Array(arg.Ids), pq.Array(arg.Urls))
+	return err
+}
+
+const updatePostContent = `-- name: UpdatePostContent :exec
+UPDATE posts
+SET title = $2, url = $3, description = $4, content_hash = $5, updated_at = $6
+WHERE id = $1
+`
+
+type UpdatePostContentParams struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	Description sql.NullString
+	ContentHash string
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) UpdatePostContent(ctx context.Context, arg UpdatePostContentParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostContent,
+		arg.ID,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.ContentHash,
+		arg.UpdatedAt,
+	)
+	return err
+}
@@ -14,21 +14,24 @@ import (
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash, is_admin)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    NOT EXISTS (SELECT 1 FROM users)
 )
-RETURNING id, created_at, updated_at, name
+RETURNING id, created_at, updated_at, name, password_hash, is_admin
 `
 
 type CreateUserParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      sql.NullString
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Name         sql.NullString
+	PasswordHash sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -37,6 +40,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.CreatedAt,
 		arg.UpdatedAt,
 		arg.Name,
+		arg.PasswordHash,
 	)
 	var i User
 	err := row.Scan(
@@ -44,21 +48,39 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
-const deleteUsers = `-- name: DeleteUsers :exec
+const deleteUser = `-- name: DeleteUser :execrows
 DELETE FROM users
+WHERE id = $1
 `
 
-func (q *Queries) DeleteUsers(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, deleteUsers)
-	return err
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteUsers = `-- name: DeleteUsers :execrows
+DELETE FROM users
+`
+
+func (q *Queries) DeleteUsers(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUsers)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, name
+SELECT id, created_at, updated_at, name, password_hash, is_admin
 FROM users 
 WHERE name = $1
 `
@@ -71,6 +93,38 @@ func (q *Queries) GetUser(ctx context.Context, name sql.NullString) (User, error
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Name,
+		&i.PasswordHash,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserDataCounts = `-- name: GetUserDataCounts :one
+SELECT
+    (SELECT COUNT(*) FROM feed_follows WHERE feed_follows.user_id = $1::UUID) AS follows,
+    (SELECT COUNT(*) FROM feed_tags WHERE feed_tags.user_id = $1::UUID) AS tags,
+    (SELECT COUNT(*) FROM post_states WHERE post_states.user_id = $1::UUID) AS post_states,
+    (SELECT COUNT(*) FROM api_tokens WHERE api_tokens.user_id = $1::UUID) AS tokens,
+    (SELECT COUNT(*) FROM feeds WHERE feeds.user_id = $1::UUID) AS feeds
+`
+
+type GetUserDataCountsRow struct {
+	Follows    int64
+	Tags       int64
+	PostStates int64
+	Tokens     int64
+	Feeds      int64
+}
+
+func (q *Queries) GetUserDataCounts(ctx context.Context, userID uuid.UUID) (GetUserDataCountsRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserDataCounts, userID)
+	var i GetUserDataCountsRow
+	err := row.Scan(
+		&i.Follows,
+		&i.Tags,
+		&i.PostStates,
+		&i.Tokens,
+		&i.Feeds,
 	)
 	return i, err
 }
@@ -89,23 +143,30 @@ func (q *Queries) GetUserNameByID(ctx context.Context, id uuid.UUID) (sql.NullSt
 }
 
 const getUsers = `-- name: GetUsers :many
-SELECT name
+SELECT id, created_at, updated_at, name, password_hash, is_admin
 FROM users
 `
 
-func (q *Queries) GetUsers(ctx context.Context) ([]sql.NullString, error) {
+func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	rows, err := q.db.QueryContext(ctx, getUsers)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []sql.NullString
+	var items []User
 	for rows.Next() {
-		var name sql.NullString
-		if err := rows.Scan(&name); err != nil {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.PasswordHash,
+			&i.IsAdmin,
+		); err != nil {
 			return nil, err
 		}
-		items = append(items, name)
+		items = append(items, i)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -115,3 +176,19 @@ func (q *Queries) GetUsers(ctx context.Context) ([]sql.NullString, error) {
 	}
 	return items, nil
 }
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1
+`
+
+type SetUserPasswordParams struct {
+	ID           uuid.UUID
+	PasswordHash sql.NullString
+}
+
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
@@ -25,7 +25,7 @@ func main() {
 	}	
 	dbQueries := database.New(db)
 
-	cfg := newState(&cfgInitial, dbQueries)		
+	cfg := newState(&cfgInitial, db, dbQueries)		
 	cmds := newCommands()	
 
 	cmd, err := argsToCommand(os.Args) 
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+type OPMLDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title,omitempty"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+		OwnerName   string `xml:"ownerName,omitempty"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []OPMLOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Category string        `xml:"category,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// opmlFeed is a single subscription pulled out of an OPML tree along with
+// the folder names it was nested under
+type opmlFeed struct {
+	Name    string
+	URL     string
+	SiteURL string
+	Tags    []string
+}
+
+type opmlImportResult struct {
+	Created  int
+	Existing int
+	Failed   int
+}
+
+func readOPML(path string) (OPMLDocument, error) {
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return OPMLDocument{}, err
+	}
+
+	var doc OPMLDocument
+	err = xml.Unmarshal(body, &doc)
+	if err != nil {
+		return OPMLDocument{}, fmt.Errorf("invalid opml: %w", err)
+	}
+
+	return doc, nil
+}
+
+func (doc *OPMLDocument) feeds() []opmlFeed {
+	var result []opmlFeed
+	collectOPMLFeeds(doc.Body.Outlines, nil, &result)
+	return result
+}
+
+func collectOPMLFeeds(outlines []OPMLOutline, folders []string, result *[]opmlFeed) {
+
+	for _, o := range outlines {
+		name := strings.TrimSpace(o.Text)
+		if name == "" {
+			name = strings.TrimSpace(o.Title)
+		}
+
+		// outlines without an xmlUrl are folders, their names become tags
+		if o.XMLURL == "" {
+			nested := folders
+			if name != "" {
+				nested = append(append([]string{}, folders...), name)
+			}
+			collectOPMLFeeds(o.Outlines, nested, result)
+			continue
+		}
+
+		if name == "" {
+			name = o.XMLURL
+		}
+
+		tags := append(append([]string{}, folders...), categoryTags(o.Category)...)
+		*result = append(*result, opmlFeed{
+			Name:    name,
+			URL:     strings.TrimSpace(o.XMLURL),
+			SiteURL: strings.TrimSpace(o.HTMLURL),
+			Tags:    uniqueTags(tags),
+		})
+		collectOPMLFeeds(o.Outlines, folders, result)
+	}
+}
+
+// categoryTags splits an OPML 2.0 category attribute ("/Tech/Go,News") into tags
+func categoryTags(category string) []string {
+	var tags []string
+	for _, path := range strings.Split(category, ",") {
+		for _, v := range strings.Split(path, "/") {
+			v = strings.TrimSpace(v)
+			if v != "" {
+				tags = append(tags, v)
+			}
+		}
+	}
+	return tags
+}
+
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, v := range tags {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	return result
+}
+
+func importOPMLFeed(s *state, user database.User, f opmlFeed) (bool, error) {
+
+	added, err := addFeed(s, user, f.Name, f.URL)
+	if err != nil {
+		return false, err
+	}
+	created := added.Created
+	feedID := added.Feed.ID
+
+	if created && f.SiteURL != "" {
+		err = s.db.SetFeedSiteURL(context.Background(), database.SetFeedSiteURLParams{
+			ID: feedID,
+			SiteUrl: sql.NullString{String: f.SiteURL, Valid: true},
+		})
+		if err != nil {
+			return created, err
+		}
+	}
+
+	for _, tag := range f.Tags {
+		params := database.AddFeedTagParams{
+			ID: uuid.New(),
+			CreatedAt: time.Now(),
+			UserID: user.ID,
+			FeedID: feedID,
+			Name: tag,
+		}
+
+		err = s.db.AddFeedTag(context.Background(), params)
+		if err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
+
+func importOPML(s *state, user database.User, doc OPMLDocument) opmlImportResult {
+
+	var result opmlImportResult
+	for _, f := range doc.feeds() {
+		created, err := importOPMLFeed(s, user, f)
+		if err != nil {
+			fmt.Printf("failed: %s: %v\n", f.URL, err)
+			result.Failed++
+			continue
+		}
+
+		if created {
+			result.Created++
+		} else {
+			result.Existing++
+		}
+	}
+
+	return result
+}
+
+func feedOutline(f database.GetFeedFollowsForUserRow) OPMLOutline {
+	return OPMLOutline{
+		Text: f.FeedTitle,
+		Title: f.FeedTitle,
+		Type: "rss",
+		XMLURL: f.FeedUrl.String,
+		HTMLURL: f.FeedSiteUrl.String,
+	}
+}
+
+// buildOPML groups the user's follows into one folder per tag, feeds with no
+// tags sit at the top level. With onlyTag set just that folder is exported
+func buildOPML(s *state, user database.User, onlyTag string) (OPMLDocument, error) {
+
+	follows, err := s.db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return OPMLDocument{}, err
+	}
+	sort.Slice(follows, func(i, j int) bool {
+		return strings.ToLower(follows[i].FeedTitle) < strings.ToLower(follows[j].FeedTitle)
+	})
+
+	feedTags, err := s.db.GetFeedTagsForUser(context.Background(), user.ID)
+	if err != nil {
+		return OPMLDocument{}, err
+	}
+	tagged := make(map[uuid.UUID]bool)
+	var tagNames []string
+	folders := make(map[string][]OPMLOutline)
+	for _, t := range feedTags {
+		tagged[t.FeedID] = true
+		if _, ok := folders[t.Name]; !ok {
+			tagNames = append(tagNames, t.Name)
+			folders[t.Name] = nil
+		}
+	}
+
+	var doc OPMLDocument
+	doc.Version = "2.0"
+	doc.Head.Title = fmt.Sprintf("gator subscriptions for %s", user.Name.String)
+	doc.Head.DateCreated = time.Now().Format(time.RFC1123Z)
+	doc.Head.OwnerName = user.Name.String
+
+	for _, f := range follows {
+		if !tagged[f.FeedID] && onlyTag == "" {
+			doc.Body.Outlines = append(doc.Body.Outlines, feedOutline(f))
+		}
+	}
+
+	byFeed := make(map[uuid.UUID]database.GetFeedFollowsForUserRow)
+	for _, f := range follows {
+		byFeed[f.FeedID] = f
+	}
+	for _, t := range feedTags {
+		f, ok := byFeed[t.FeedID]
+		if !ok {
+			continue
+		}
+		folders[t.Name] = append(folders[t.Name], feedOutline(f))
+	}
+
+	for _, name := range tagNames {
+		if onlyTag != "" && name != onlyTag {
+			continue
+		}
+		outlines := folders[name]
+		if len(outlines) == 0 {
+			continue
+		}
+		sort.Slice(outlines, func(i, j int) bool {
+			return strings.ToLower(outlines[i].Text) < strings.ToLower(outlines[j].Text)
+		})
+		doc.Body.Outlines = append(doc.Body.Outlines, OPMLOutline{
+			Text: name,
+			Title: name,
+			Outlines: outlines,
+		})
+	}
+
+	if onlyTag != "" && len(doc.Body.Outlines) == 0 {
+		return OPMLDocument{}, fmt.Errorf("no followed feeds tagged: %s", onlyTag)
+	}
+
+	return doc, nil
+}
+
+func writeOPML(w io.Writer, doc OPMLDocument) error {
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// ======== Formats ========
+
+var outputFormats = []string{"text", "json", "jsonl", "csv", "tsv"}
+
+func validOutputFormat(format string) bool {
+	for _, v := range outputFormats {
+		if v == format {
+			return true
+		}
+	}
+	return false
+}
+
+// printListing writes rows in the requested format. Text keeps each command's
+// own human readable layout, everything else is derived from the json tags
+// on the record type so field names stay the same across formats
+func printListing[T any](format string, rows []T, printText func([]T)) error {
+
+	switch format {
+	case "", "text":
+		printText(rows)
+		return nil
+	case "json":
+		if rows == nil {
+			rows = []T{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case "jsonl":
+		enc := json.NewEncoder(os.Stdout)
+		for _, v := range rows {
+			err := enc.Encode(v)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		return writeDelimited(os.Stdout, ',', rows)
+	case "tsv":
+		return writeDelimited(os.Stdout, '\t', rows)
+	}
+
+	return fmt.Errorf("unknown output format: %s (want %s)", format, strings.Join(outputFormats, "|"))
+}
+
+func writeDelimited[T any](w io.Writer, sep rune, rows []T) error {
+
+	recordType := reflect.TypeOf((*T)(nil)).Elem()
+	var header []string
+	for i := 0; i < recordType.NumField(); i++ {
+		name, _, _ := strings.Cut(recordType.Field(i).Tag.Get("json"), ",")
+		header = append(header, name)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Comma = sep
+	err := cw.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		v := reflect.ValueOf(row)
+		var line []string
+		for i := 0; i < v.NumField(); i++ {
+			line = append(line, formatField(v.Field(i)))
+		}
+		err = cw.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatField(v reflect.Value) string {
+
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch field := v.Interface().(type) {
+	case time.Time:
+		return field.Format(time.RFC3339)
+	case fmt.Stringer:
+		return field.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func nullString(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+func stringValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func nullTime(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
+
+func nullUUID(v uuid.NullUUID) *uuid.UUID {
+	if !v.Valid {
+		return nil
+	}
+	return &v.UUID
+}
+
+// ======== Records ========
+
+type userRecord struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      *string   `json:"name"`
+	Admin     bool      `json:"admin"`
+	Current   bool      `json:"current"`
+}
+
+func newUserRecord(u database.User, currentUserName string) userRecord {
+	return userRecord{
+		ID: u.ID,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Name: nullString(u.Name),
+		Admin: u.IsAdmin,
+		Current: u.Name.Valid && u.Name.String == currentUserName,
+	}
+}
+
+type feedRecord struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Name          string     `json:"name"`
+	Url           *string    `json:"url"`
+	SiteUrl       *string    `json:"site_url"`
+	UserID        *uuid.UUID `json:"user_id"`
+	CreatorName   *string    `json:"creator_name"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+}
+
+func newFeedRecord(f database.Feed, creatorName sql.NullString) feedRecord {
+	return feedRecord{
+		ID: f.ID,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+		Name: f.Name,
+		Url: nullString(f.Url),
+		SiteUrl: nullString(f.SiteUrl),
+		UserID: nullUUID(f.UserID),
+		CreatorName: nullString(creatorName),
+		LastFetchedAt: nullTime(f.LastFetchedAt),
+	}
+}
+
+type followRecord struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	UserName    *string   `json:"user_name"`
+	DisplayName *string   `json:"display_name"`
+	FeedName    string    `json:"feed_name"`
+	FeedTitle   string    `json:"feed_title"`
+	FeedUrl     *string   `json:"feed_url"`
+	FeedSiteUrl *string   `json:"feed_site_url"`
+}
+
+func newFollowRecord(f database.GetFeedFollowsForUserRow) followRecord {
+	return followRecord{
+		ID: f.ID,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+		FeedID: f.FeedID,
+		UserID: f.UserID,
+		UserName: nullString(f.UserName),
+		DisplayName: nullString(f.DisplayName),
+		FeedName: f.FeedName,
+		FeedTitle: f.FeedTitle,
+		FeedUrl: nullString(f.FeedUrl),
+		FeedSiteUrl: nullString(f.FeedSiteUrl),
+	}
+}
+
+type postRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Title       string     `json:"title"`
+	Url         string     `json:"url"`
+	Description *string    `json:"description"`
+	PublishedAt time.Time  `json:"published_at"`
+	FeedID      uuid.UUID  `json:"feed_id"`
+	FeedTitle   string     `json:"feed_title"`
+	FeedUrl     *string    `json:"feed_url"`
+	ReadAt      *time.Time `json:"read_at"`
+	StarredAt   *time.Time `json:"starred_at"`
+	Highlighted bool       `json:"highlighted"`
+}
+
+func newPostRecord(p database.GetPostsForUserRow) postRecord {
+	return postRecord{
+		ID: p.ID,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		Title: p.Title,
+		Url: p.Url,
+		Description: nullString(p.Description),
+		PublishedAt: p.PublishedAt,
+		FeedID: p.FeedID,
+		FeedTitle: p.FeedTitle,
+		FeedUrl: nullString(p.FeedUrl),
+		ReadAt: nullTime(p.ReadAt),
+		StarredAt: nullTime(p.StarredAt),
+		Highlighted: p.Highlighted,
+	}
+}
+
+type tokenRecord struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Fever      bool       `json:"fever"`
+	Current    bool       `json:"current"`
+}
+
+func newTokenRecord(t database.ApiToken, currentHash string) tokenRecord {
+	return tokenRecord{
+		ID: t.ID,
+		Name: t.Name,
+		CreatedAt: t.CreatedAt,
+		LastUsedAt: nullTime(t.LastUsedAt),
+		Fever: t.FeverKeyHash.Valid,
+		Current: t.TokenHash == currentHash,
+	}
+}
+
+type filterRecord struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Action     string     `json:"action"`
+	TitleRegex *string    `json:"title_regex"`
+	Keyword    *string    `json:"keyword"`
+	FeedID     *uuid.UUID `json:"feed_id"`
+	FeedName   *string    `json:"feed_name"`
+	FeedUrl    *string    `json:"feed_url"`
+}
+
+func newFilterRecord(r database.GetFilterRulesForUserRow) filterRecord {
+	return filterRecord{
+		ID: r.ID,
+		CreatedAt: r.CreatedAt,
+		Action: r.Action,
+		TitleRegex: nullString(r.TitleRegex),
+		Keyword: nullString(r.Keyword),
+		FeedID: nullUUID(r.FeedID),
+		FeedName: nullString(r.FeedName),
+		FeedUrl: nullString(r.FeedUrl),
+	}
+}
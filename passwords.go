@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/colfarl/gator/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+const minPasswordLength = 8
+
+var errWrongPassword = errors.New("wrong user name or password")
+
+var stdinLines *bufio.Reader
+
+// readPassword prompts without echo on a terminal. Piped input is read a line
+// at a time so scripts can do: echo "$PASSWORD" | gator login bob
+func readPassword(prompt string) (string, error) {
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		if stdinLines == nil {
+			stdinLines = bufio.NewReader(os.Stdin)
+		}
+		line, err := stdinLines.ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("no password given on stdin")
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// readNewPassword asks twice on a terminal so a typo doesn't lock anyone out
+func readNewPassword() (string, error) {
+
+	password, err := readPassword("New password: ")
+	if err != nil {
+		return "", err
+	}
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		repeated, err := readPassword("Repeat password: ")
+		if err != nil {
+			return "", err
+		}
+		if repeated != password {
+			return "", fmt.Errorf("passwords do not match")
+		}
+	}
+	return password, nil
+}
+
+// confirm asks a yes/no question on a terminal. Without one there is nobody to
+// ask, so destructive commands need --yes instead
+func confirm(question string, yes bool) error {
+
+	if yes {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("stdin is not a terminal, pass --yes to confirm")
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	if stdinLines == nil {
+		stdinLines = bufio.NewReader(os.Stdin)
+	}
+	answer, _ := stdinLines.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("aborted")
+}
+
+func hashPassword(password string) (sql.NullString, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(hash), Valid: true}, nil
+}
+
+func checkPassword(user database.User, password string) error {
+	if !user.PasswordHash.Valid {
+		return errWrongPassword
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(password))
+	if err != nil {
+		return errWrongPassword
+	}
+	return nil
+}
+
+// logInWithPassword is the check behind both gator login and the web login
+func logInWithPassword(s *state, name, password string) (database.User, error) {
+
+	user, err := s.db.GetUser(context.Background(), sql.NullString{String: name, Valid: name != ""})
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, errWrongPassword
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = checkPassword(user, password)
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func setPassword(s *state, user database.User) error {
+
+	password, err := readNewPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return s.db.SetUserPassword(context.Background(), database.SetUserPasswordParams{
+		ID: user.ID,
+		PasswordHash: hash,
+	})
+}
+
+// startSession gives the CLI its own API token and remembers it in the
+// config file, replacing (and revoking) the one from the last login
+func startSession(s *state, user database.User) error {
+
+	err := endSession(s)
+	if err != nil {
+		return err
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+
+	token, _, err := createAPIToken(s, user, "cli@"+host, false)
+	if err != nil {
+		return err
+	}
+	return s.updateUser(user.Name.String, token)
+}
+
+// endSession revokes the token saved by the last login, if there is one
+func endSession(s *state) error {
+
+	token := s.CurrentState.CurrentUserToken
+	if token == "" {
+		return nil
+	}
+
+	err := s.db.DeleteAPITokenByHash(context.Background(), hashAPIToken(token))
+	if err != nil {
+		return err
+	}
+	return s.updateUser("", "")
+}
+
+// sessionToken is $GATOR_TOKEN when it is set, otherwise the token saved by
+// gator login
+func sessionToken(s *state) string {
+	token := os.Getenv("GATOR_TOKEN")
+	if token == "" {
+		token = s.CurrentState.CurrentUserToken
+	}
+	return token
+}
+
+// currentUser is whoever the session token belongs to
+func currentUser(s *state) (database.User, error) {
+
+	token := sessionToken(s)
+	if token == "" {
+		return database.User{}, fmt.Errorf("not logged in, run: gator login <user-name>")
+	}
+
+	user, err := userForAPIToken(s, token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("your session token was revoked, log in again")
+	}
+	return user, err
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// Retention has a default in retention_default that feed_retention can
+// override per feed, 0 meaning no limit. Feeds are shared, so only the admin
+// changes the default and only the admin or whoever added a feed its override. A post is pruned once it is outside every
+// limit that applies (not among the newest keep-posts of its feed, older than
+// keep-days), everyone following its feed has read it, and nobody starred it.
+// Pruned posts leave a tombstone in pruned_posts so agg doesn't fetch them
+// again while the feed still lists them
+
+type prunedFeed struct {
+	Name  string
+	Url   string
+	Posts int64
+}
+
+func describeRetention(keepPosts, keepDays int) string {
+	switch {
+	case keepPosts > 0 && keepDays > 0:
+		return fmt.Sprintf("keep the newest %d posts and anything from the last %d days", keepPosts, keepDays)
+	case keepPosts > 0:
+		return fmt.Sprintf("keep the newest %d posts", keepPosts)
+	case keepDays > 0:
+		return fmt.Sprintf("keep posts from the last %d days", keepDays)
+	}
+	return "keep everything"
+}
+
+// prunePosts deletes (or with dryRun only counts) the posts retention lets go,
+// per feed
+func prunePosts(s *state, dryRun bool) ([]prunedFeed, error) {
+
+	counts := map[uuid.UUID]int64{}
+	if dryRun {
+		rows, err := s.db.CountPrunablePosts(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range rows {
+			counts[v.FeedID] = v.Count
+		}
+	} else {
+		rows, err := s.db.PrunePosts(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range rows {
+			counts[v.FeedID] = v.Count
+		}
+	}
+	if len(counts) == 0 {
+		return nil, nil
+	}
+
+	feeds, err := s.db.GetFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []prunedFeed
+	for _, v := range feeds {
+		if counts[v.ID] > 0 {
+			pruned = append(pruned, prunedFeed{Name: v.Name, Url: v.Url.String, Posts: counts[v.ID]})
+		}
+	}
+	sort.Slice(pruned, func(i, j int) bool { return pruned[i].Name < pruned[j].Name })
+	return pruned, nil
+}
+
+func printPrunedFeeds(pruned []prunedFeed, verb string) {
+	var total int64
+	for _, v := range pruned {
+		fmt.Printf(" * %s (%s): %d posts\n", v.Name, v.Url, v.Posts)
+		total += v.Posts
+	}
+	fmt.Printf("%s %d posts from %d feeds\n", verb, total, len(pruned))
+}
+
+func handlerPrune(s *state, cmd command) error {
+
+	dryRun := cmd.flagBool("dry-run")
+	pruned, err := prunePosts(s, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if len(pruned) == 0 {
+		fmt.Println("nothing to prune")
+		return nil
+	}
+	if dryRun {
+		printPrunedFeeds(pruned, "would prune")
+		return nil
+	}
+	printPrunedFeeds(pruned, "pruned")
+	return nil
+}
+
+func handlerRetention(s *state, cmd command) error {
+
+	defaults, err := s.db.GetRetentionDefault(context.Background())
+	if err != nil {
+		return err
+	}
+	overrides, err := s.db.GetFeedRetentions(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("default:", describeRetention(int(defaults.KeepPosts), int(defaults.KeepDays)))
+	for _, v := range overrides {
+		keepPosts, keepDays := int(defaults.KeepPosts), int(defaults.KeepDays)
+		if v.KeepPosts.Valid {
+			keepPosts = int(v.KeepPosts.Int32)
+		}
+		if v.KeepDays.Valid {
+			keepDays = int(v.KeepDays.Int32)
+		}
+		fmt.Printf(" * %s (%s): %s\n", v.FeedName, v.FeedUrl.String, describeRetention(keepPosts, keepDays))
+	}
+	return nil
+}
+
+// feedIDForRetention finds the feed whose override user wants to change,
+// which only the admin and whoever added the feed may do
+func feedIDForRetention(s *state, user database.User, url string) (uuid.UUID, error) {
+
+	feed, err := lookupFeed(context.Background(), s.db, url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.UUID{}, fmt.Errorf("no feed with url %s", url)
+	}
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	if !user.IsAdmin && feed.UserID.UUID != user.ID {
+		return uuid.UUID{}, fmt.Errorf("only the admin or whoever added %s can change its retention", url)
+	}
+	return feed.ID, nil
+}
+
+// setRetentionDefault changes the default for every feed, admin only
+func setRetentionDefault(s *state, user database.User, keepPosts, keepDays int) error {
+
+	if !user.IsAdmin {
+		return fmt.Errorf("only the admin can change the default retention, pass --feed for a feed you added")
+	}
+	return s.db.SetRetentionDefault(context.Background(), database.SetRetentionDefaultParams{
+		UpdatedAt: time.Now(),
+		KeepPosts: int32(keepPosts),
+		KeepDays: int32(keepDays),
+	})
+}
+
+// handlerRetentionSet changes only the limits given, -1 being "not given"
+func handlerRetentionSet(s *state, cmd command, user database.User) error {
+
+	keepPosts, keepDays := cmd.flagInt("keep-posts"), cmd.flagInt("keep-days")
+	if keepPosts < -1 || keepDays < -1 || (keepPosts == -1 && keepDays == -1) {
+		return cmd.usageError()
+	}
+
+	feedURL := cmd.flagString("feed")
+	if feedURL == "" {
+		defaults, err := s.db.GetRetentionDefault(context.Background())
+		if err != nil {
+			return err
+		}
+		if keepPosts == -1 {
+			keepPosts = int(defaults.KeepPosts)
+		}
+		if keepDays == -1 {
+			keepDays = int(defaults.KeepDays)
+		}
+		err = setRetentionDefault(s, user, keepPosts, keepDays)
+		if err != nil {
+			return err
+		}
+		fmt.Println("default:", describeRetention(keepPosts, keepDays))
+		return nil
+	}
+
+	feedID, err := feedIDForRetention(s, user, feedURL)
+	if err != nil {
+		return err
+	}
+
+	params := database.SetFeedRetentionParams{
+		FeedID: feedID,
+		UpdatedAt: time.Now(),
+	}
+	overrides, err := s.db.GetFeedRetentions(context.Background())
+	if err != nil {
+		return err
+	}
+	for _, v := range overrides {
+		if v.FeedID == feedID {
+			params.KeepPosts, params.KeepDays = v.KeepPosts, v.KeepDays
+		}
+	}
+	if keepPosts != -1 {
+		params.KeepPosts = sql.NullInt32{Int32: int32(keepPosts), Valid: true}
+	}
+	if keepDays != -1 {
+		params.KeepDays = sql.NullInt32{Int32: int32(keepDays), Valid: true}
+	}
+
+	err = s.db.SetFeedRetention(context.Background(), params)
+	if err != nil {
+		return err
+	}
+	return handlerRetention(s, cmd)
+}
+
+func handlerRetentionClear(s *state, cmd command, user database.User) error {
+
+	feedURL := cmd.flagString("feed")
+	if feedURL == "" {
+		err := setRetentionDefault(s, user, 0, 0)
+		if err != nil {
+			return err
+		}
+		return handlerRetention(s, cmd)
+	}
+
+	feedID, err := feedIDForRetention(s, user, feedURL)
+	if err != nil {
+		return err
+	}
+
+	cleared, err := s.db.DeleteFeedRetention(context.Background(), feedID)
+	if err != nil {
+		return err
+	}
+	if cleared == 0 {
+		return fmt.Errorf("%s has no retention of its own", feedURL)
+	}
+	return handlerRetention(s, cmd)
+}
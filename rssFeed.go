@@ -9,8 +9,11 @@ import (
 	"net/http"
 )
 
+// Base is xml:base, which encoding/xml files under the xml namespace uri
 type RSSFeed struct {
+	Base    string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Channel struct {
+		Base        string    `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 		Title       string    `xml:"title"`
 		Link        string    `xml:"link"`
 		Description string    `xml:"description"`
@@ -19,10 +22,12 @@ type RSSFeed struct {
 }
 
 type RSSItem struct {
+	Base        string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
 	Title       string `xml:"title"`
 	Link        string `xml:"link"`
 	Description string `xml:"description"`
 	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
 }
 
 func (feed *RSSFeed) unEscape()  {
@@ -52,6 +57,7 @@ func (feed *RSSFeed) print() {
 		fmt.Printf(" - Link: %s\n", v.Link)
 		fmt.Printf(" - Description: %s\n", v.Description)
 		fmt.Printf(" - Publication Date: %s\n", v.PubDate)
+		fmt.Printf(" - GUID: %s\n", v.GUID)
 		fmt.Println()
 	}
 }
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	apiPrefix       = "/api/v1"
+	apiDefaultLimit = 50
+	apiMaxLimit     = 500
+)
+
+type apiHandler func(w http.ResponseWriter, r *http.Request, user database.User)
+
+// ======== Helpers ========
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...any) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, args...)})
+}
+
+// writeDBError maps missing rows to 404 and keeps other database errors
+// out of the response
+func writeDBError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	fmt.Println("api error:", err)
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// apiLoggedIn is the server side of middlewareLoggedIn, the user comes from
+// the bearer token instead of the config file
+func apiLoggedIn(s *state, handler apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		user, err := userForAPIToken(s, token)
+		if errors.Is(err, sql.ErrNoRows) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gator", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		handler(w, r, user)
+	}
+}
+
+func queryBool(r *http.Request, key string) (sql.NullBool, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return sql.NullBool{}, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return sql.NullBool{}, fmt.Errorf("%s must be true or false", key)
+	}
+	return sql.NullBool{Bool: b, Valid: true}, nil
+}
+
+func queryInt(r *http.Request, key string, fallback, low, high int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < low || n > high {
+		return 0, fmt.Errorf("%s must be a number from %d to %d", key, low, high)
+	}
+	return n, nil
+}
+
+// ======== Routes ========
+
+func registerAPIRoutes(mux *http.ServeMux, s *state) {
+
+	mux.HandleFunc("GET "+apiPrefix+"/me", apiLoggedIn(s, apiGetMe))
+	mux.HandleFunc("GET "+apiPrefix+"/users", apiLoggedIn(s, apiGetUsers(s)))
+	mux.HandleFunc("GET "+apiPrefix+"/feeds", apiLoggedIn(s, apiGetFeeds(s)))
+	mux.HandleFunc("POST "+apiPrefix+"/feeds", apiLoggedIn(s, apiCreateFeed(s)))
+	mux.HandleFunc("GET "+apiPrefix+"/follows", apiLoggedIn(s, apiGetFollows(s)))
+	mux.HandleFunc("POST "+apiPrefix+"/follows", apiLoggedIn(s, apiCreateFollow(s)))
+	mux.HandleFunc("DELETE "+apiPrefix+"/follows/{feed_id}", apiLoggedIn(s, apiDeleteFollow(s)))
+	mux.HandleFunc("GET "+apiPrefix+"/tags", apiLoggedIn(s, apiGetTags(s)))
+	mux.HandleFunc("GET "+apiPrefix+"/posts", apiLoggedIn(s, apiGetPosts(s)))
+	mux.HandleFunc("GET "+apiPrefix+"/posts/{id}", apiLoggedIn(s, apiGetPost(s)))
+	mux.HandleFunc("PUT "+apiPrefix+"/posts/{id}/read", apiLoggedIn(s, apiSetRead(s, true)))
+	mux.HandleFunc("DELETE "+apiPrefix+"/posts/{id}/read", apiLoggedIn(s, apiSetRead(s, false)))
+	mux.HandleFunc("PUT "+apiPrefix+"/posts/{id}/star", apiLoggedIn(s, apiSetStarred(s, true)))
+	mux.HandleFunc("DELETE "+apiPrefix+"/posts/{id}/star", apiLoggedIn(s, apiSetStarred(s, false)))
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no such endpoint: %s %s", r.Method, r.URL.Path)
+	})
+}
+
+func apiGetMe(w http.ResponseWriter, r *http.Request, user database.User) {
+	writeJSON(w, http.StatusOK, newUserRecord(user, user.Name.String))
+}
+
+func apiGetUsers(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		users, err := s.db.GetUsers(r.Context())
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		records := []userRecord{}
+		for _, v := range users {
+			records = append(records, newUserRecord(v, user.Name.String))
+		}
+		writeJSON(w, http.StatusOK, records)
+	}
+}
+
+func apiGetFeeds(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		feeds, err := s.db.GetFeeds(r.Context())
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		records := []feedRecord{}
+		for _, v := range feeds {
+			creatorName, err := s.db.GetUserNameByID(r.Context(), v.UserID.UUID)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				writeDBError(w, err)
+				return
+			}
+			records = append(records, newFeedRecord(v, creatorName))
+		}
+		writeJSON(w, http.StatusOK, records)
+	}
+}
+
+type apiCreateFeedRequest struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+func apiCreateFeed(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		var req apiCreateFeedRequest
+		err := readJSON(r, &req)
+		if err != nil || req.Name == "" || req.Url == "" {
+			writeError(w, http.StatusBadRequest, `body must be {"name": "...", "url": "..."}`)
+			return
+		}
+
+		// like addfeed, an existing feed is just followed and comes back with
+		// 200 instead of 201
+		added, err := addFeed(s, user, req.Name, req.Url)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		if added.Created {
+			writeJSON(w, http.StatusCreated, newFeedRecord(added.Feed, user.Name))
+			return
+		}
+
+		creatorName, err := s.db.GetUserNameByID(r.Context(), added.Feed.UserID.UUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			writeDBError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newFeedRecord(added.Feed, creatorName))
+	}
+}
+
+func followRecordFor(s *state, ctx context.Context, user database.User, feedID uuid.UUID) (followRecord, error) {
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return followRecord{}, err
+	}
+	for _, v := range follows {
+		if v.FeedID == feedID {
+			return newFollowRecord(v), nil
+		}
+	}
+	return followRecord{}, sql.ErrNoRows
+}
+
+func apiGetFollows(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		follows, err := s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		records := []followRecord{}
+		for _, v := range follows {
+			records = append(records, newFollowRecord(v))
+		}
+		writeJSON(w, http.StatusOK, records)
+	}
+}
+
+type apiCreateFollowRequest struct {
+	FeedID *uuid.UUID `json:"feed_id"`
+	Url    string     `json:"url"`
+}
+
+func apiCreateFollow(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		var req apiCreateFollowRequest
+		err := readJSON(r, &req)
+		if err != nil || (req.FeedID == nil && req.Url == "") {
+			writeError(w, http.StatusBadRequest, `body must be {"feed_id": "..."} or {"url": "..."}`)
+			return
+		}
+
+		var feedID uuid.UUID
+		if req.FeedID != nil {
+			feed, err := s.db.GetFeedByID(r.Context(), *req.FeedID)
+			if err != nil {
+				writeDBError(w, err)
+				return
+			}
+			feedID = feed.ID
+		} else {
+			feed, err := lookupFeed(r.Context(), s.db, req.Url)
+			if err != nil {
+				writeDBError(w, err)
+				return
+			}
+			feedID = feed.ID
+		}
+
+		created, err := followFeed(s.db, user, feedID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+
+		record, err := followRecordFor(s, r.Context(), user, feedID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		writeJSON(w, status, record)
+	}
+}
+
+func apiDeleteFollow(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		feedID, err := uuid.Parse(r.PathValue("feed_id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "feed_id must be a uuid")
+			return
+		}
+
+		params := database.DeleteFeedFollowParams{
+			UserID: user.ID,
+			FeedID: feedID,
+		}
+
+		err = s.db.DeleteFeedFollow(r.Context(), params)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func apiGetTags(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		tags, err := s.db.GetFeedTagsForUser(r.Context(), user.ID)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		var names []string
+		for _, v := range tags {
+			names = append(names, v.Name)
+		}
+		names = uniqueTags(names)
+		if names == nil {
+			names = []string{}
+		}
+		writeJSON(w, http.StatusOK, names)
+	}
+}
+
+type apiPostPage struct {
+	Posts      []postRecord `json:"posts"`
+	Limit      int          `json:"limit"`
+	Offset     int          `json:"offset"`
+	NextOffset *int         `json:"next_offset"`
+}
+
+// apiGetPosts lists the timeline, filtered by feed_id, tag, read, starred and
+// a q search and paged with limit/offset. next_offset is null on the last page
+func apiGetPosts(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		limit, err := queryInt(r, "limit", apiDefaultLimit, 1, apiMaxLimit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		offset, err := queryInt(r, "offset", 0, 0, 1<<30)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		read, err := queryBool(r, "read")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		starred, err := queryBool(r, "starred")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		updated, err := queryBool(r, "updated")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+		showHidden, err := queryBool(r, "show_hidden")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "%v", err)
+			return
+		}
+
+		params := database.GetPostsForUserParams{
+			UserID: user.ID,
+			Read: read,
+			Starred: starred,
+			Updated: updated,
+			ShowHidden: showHidden.Bool,
+			Limit: int32(limit + 1),
+			Offset: int32(offset),
+		}
+		if v := r.URL.Query().Get("feed_id"); v != "" {
+			feedID, err := uuid.Parse(v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "feed_id must be a uuid")
+				return
+			}
+			params.FeedID = uuid.NullUUID{UUID: feedID, Valid: true}
+		}
+		if v := r.URL.Query().Get("tag"); v != "" {
+			params.Tag = sql.NullString{String: v, Valid: true}
+		}
+		if v := strings.TrimSpace(r.URL.Query().Get("q")); v != "" {
+			params.Search = sql.NullString{String: v, Valid: true}
+		}
+
+		posts, err := s.db.GetPostsForUser(r.Context(), params)
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+
+		page := apiPostPage{Posts: []postRecord{}, Limit: limit, Offset: offset}
+		if len(posts) > limit {
+			posts = posts[:limit]
+			next := offset + limit
+			page.NextOffset = &next
+		}
+		for _, v := range posts {
+			page.Posts = append(page.Posts, newPostRecord(v))
+		}
+		writeJSON(w, http.StatusOK, page)
+	}
+}
+
+func apiFindPost(s *state, w http.ResponseWriter, r *http.Request, user database.User) (database.GetPostsForUserRow, bool) {
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "post id must be a uuid")
+		return database.GetPostsForUserRow{}, false
+	}
+
+	post, err := findPost(s, user, id.String())
+	if errors.Is(err, errPostNotFound) {
+		writeError(w, http.StatusNotFound, "not found")
+		return database.GetPostsForUserRow{}, false
+	}
+	if err != nil {
+		writeDBError(w, err)
+		return database.GetPostsForUserRow{}, false
+	}
+	return post, true
+}
+
+func apiGetPost(s *state) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		post, ok := apiFindPost(s, w, r, user)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, newPostRecord(post))
+	}
+}
+
+func apiSetRead(s *state, read bool) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		post, ok := apiFindPost(s, w, r, user)
+		if !ok {
+			return
+		}
+
+		post.ReadAt = sql.NullTime{}
+		if read {
+			post.ReadAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+
+		err := s.db.SetPostRead(r.Context(), database.SetPostReadParams{
+			UserID: user.ID,
+			PostID: post.ID,
+			ReadAt: post.ReadAt,
+		})
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newPostRecord(post))
+	}
+}
+
+func apiSetStarred(s *state, starred bool) apiHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		post, ok := apiFindPost(s, w, r, user)
+		if !ok {
+			return
+		}
+
+		post.StarredAt = sql.NullTime{}
+		if starred {
+			post.StarredAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+
+		err := s.db.SetPostStarred(r.Context(), database.SetPostStarredParams{
+			UserID: user.ID,
+			PostID: post.ID,
+			StarredAt: post.StarredAt,
+		})
+		if err != nil {
+			writeDBError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newPostRecord(post))
+	}
+}
+
+// ======== Server ========
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		fmt.Printf("%s %s %s (%v)\n", time.Now().Format(time.DateTime), r.Method, r.URL.Path, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+func handlerServe(s *state, cmd command) error {
+
+	addr := cmd.flagString("listen")
+	mux := http.NewServeMux()
+	registerAPIRoutes(mux, s)
+	registerFeverRoutes(mux, s)
+	registerGReaderRoutes(mux, s)
+	if !cmd.flagBool("no-web") {
+		err := registerWebRoutes(mux, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	server := &http.Server{
+		Addr: addr,
+		Handler: logRequests(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	fmt.Printf("gator is listening on http://%s (API under %s)\n", addr, apiPrefix)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	fmt.Println("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
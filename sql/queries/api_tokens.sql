@@ -0,0 +1,47 @@
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens(id, created_at, user_id, name, token_hash, fever_key_hash)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetUserByAPITokenHash :one
+SELECT u.*
+FROM api_tokens t
+    JOIN users u ON u.id = t.user_id
+WHERE t.token_hash = $1;
+
+-- name: TouchAPIToken :exec
+UPDATE api_tokens
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE token_hash = $1;
+
+-- name: GetUserByFeverKeyHash :one
+SELECT u.*
+FROM api_tokens t
+    JOIN users u ON u.id = t.user_id
+WHERE t.fever_key_hash = $1;
+
+-- name: TouchAPITokenByFeverKeyHash :exec
+UPDATE api_tokens
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE fever_key_hash = $1;
+
+-- name: GetAPITokensForUser :many
+SELECT *
+FROM api_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens
+WHERE user_id = $1 AND id = $2;
+
+-- name: DeleteAPITokenByHash :exec
+DELETE FROM api_tokens
+WHERE token_hash = $1;
@@ -17,7 +17,8 @@ FROM inserted_feed_follow ff
     JOIN feeds f ON f.id = ff.feed_id;
 
 -- name: GetFeedFollowsForUser :many
-SELECT ff.*, u.name AS user_name, f.name AS feed_name
+SELECT ff.*, u.name AS user_name, f.name AS feed_name, f.url AS feed_url, f.site_url AS feed_site_url,
+    COALESCE(ff.display_name, f.name)::TEXT AS feed_title
 FROM feed_follows ff
     JOIN users u ON u.id = ff.user_id
     JOIN feeds f ON f.id = ff.feed_id
@@ -27,3 +28,15 @@ WHERE ff.user_id = $1;
 -- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
 WHERE user_id = $1 AND feed_id = $2;
+
+-- name: SetFeedFollowDisplayName :execrows
+UPDATE feed_follows
+SET display_name = $3, updated_at = CURRENT_TIMESTAMP
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: IsFollowingFeed :one
+SELECT EXISTS (
+    SELECT 1
+    FROM feed_follows
+    WHERE user_id = $1 AND feed_id = $2
+);
@@ -0,0 +1,15 @@
+-- name: GetFeedIconFetchedAt :one
+SELECT fetched_at
+FROM feed_icons
+WHERE feed_id = $1;
+
+-- name: SetFeedIcon :exec
+INSERT INTO feed_icons(feed_id, fetched_at, mime_type, data)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (feed_id) DO UPDATE
+SET fetched_at = EXCLUDED.fetched_at, mime_type = EXCLUDED.mime_type, data = EXCLUDED.data;
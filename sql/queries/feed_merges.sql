@@ -0,0 +1,55 @@
+-- name: MovePostStates :exec
+INSERT INTO post_states(user_id, post_id, read_at, starred_at)
+SELECT ps.user_id, np.id, ps.read_at, ps.starred_at
+FROM post_states ps
+    JOIN posts op ON op.id = ps.post_id
+    JOIN posts np ON np.feed_id = sqlc.arg('to_feed_id') AND np.guid = op.guid
+WHERE op.feed_id = sqlc.arg('from_feed_id')
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, EXCLUDED.read_at),
+    starred_at = COALESCE(post_states.starred_at, EXCLUDED.starred_at);
+
+-- name: MovePosts :exec
+UPDATE posts p
+SET feed_id = sqlc.arg('to_feed_id')
+WHERE p.feed_id = sqlc.arg('from_feed_id')
+    AND NOT EXISTS (
+	SELECT 1 FROM posts o
+	WHERE o.feed_id = sqlc.arg('to_feed_id') AND o.guid = p.guid
+    );
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows ff
+SET feed_id = sqlc.arg('to_feed_id')
+WHERE ff.feed_id = sqlc.arg('from_feed_id')
+    AND NOT EXISTS (
+	SELECT 1 FROM feed_follows o
+	WHERE o.feed_id = sqlc.arg('to_feed_id') AND o.user_id = ff.user_id
+    );
+
+-- name: MoveFeedTags :exec
+UPDATE feed_tags t
+SET feed_id = sqlc.arg('to_feed_id')
+WHERE t.feed_id = sqlc.arg('from_feed_id')
+    AND NOT EXISTS (
+	SELECT 1 FROM feed_tags o
+	WHERE o.feed_id = sqlc.arg('to_feed_id') AND o.user_id = t.user_id AND o.name = t.name
+    );
+
+-- name: MoveFeedRetention :exec
+UPDATE feed_retention
+SET feed_id = sqlc.arg('to_feed_id')
+WHERE feed_id = sqlc.arg('from_feed_id')
+    AND NOT EXISTS (SELECT 1 FROM feed_retention o WHERE o.feed_id = sqlc.arg('to_feed_id'));
+
+-- name: MoveFilterRules :exec
+UPDATE filter_rules
+SET feed_id = sqlc.arg('to_feed_id')
+WHERE feed_id = sqlc.arg('from_feed_id');
+
+-- name: MovePrunedPosts :exec
+INSERT INTO pruned_posts(feed_id, guid, pruned_at)
+SELECT sqlc.arg('to_feed_id')::UUID, guid, pruned_at
+FROM pruned_posts
+WHERE feed_id = sqlc.arg('from_feed_id')
+ON CONFLICT (feed_id, guid) DO NOTHING;
@@ -0,0 +1,20 @@
+-- name: AddFeedTag :exec
+INSERT INTO feed_tags(id, created_at, user_id, feed_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, feed_id, name) DO NOTHING;
+
+-- name: GetFeedTagsForUser :many
+SELECT feed_id, name
+FROM feed_tags
+WHERE user_id = $1
+ORDER BY name;
+
+-- name: RemoveFeedTag :exec
+DELETE FROM feed_tags
+WHERE user_id = $1 AND feed_id = $2 AND name = $3;
@@ -8,6 +8,7 @@ VALUES (
     $5,
     $6
 )
+ON CONFLICT (url) DO NOTHING
 RETURNING *;
 
 
@@ -15,9 +16,19 @@ RETURNING *;
 SELECT *
 FROM feeds;
 
--- name: GetFeedIdByURL :one
+-- name: GetFeedByID :one
+SELECT *
+FROM feeds
+WHERE id = $1;
+
+-- name: GetFeedIDBySeq :one
 SELECT id
-FROM  feeds
+FROM feeds
+WHERE seq = $1;
+
+-- name: GetFeedByURL :one
+SELECT *
+FROM feeds
 WHERE url = $1;
 
 -- name: MarkedFeedFetched :exec
@@ -28,5 +39,38 @@ WHERE id = $1;
 -- name: GetNextFeedToFetch :one
 SELECT * 
 FROM feeds
+WHERE EXISTS (SELECT 1 FROM feed_follows WHERE feed_follows.feed_id = feeds.id)
 ORDER BY last_fetched_at ASC NULLS FIRST
 LIMIT 1;
+
+-- name: ReassignFeeds :execrows
+UPDATE feeds
+SET user_id = sqlc.narg('new_user_id')
+WHERE user_id = sqlc.arg('old_user_id');
+
+-- name: SetFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2
+WHERE id = $1;
+
+-- name: GetOrphanedFeeds :many
+SELECT f.id, f.name, f.url, (SELECT COUNT(*) FROM posts p WHERE p.feed_id = f.id) AS post_count
+FROM feeds f
+WHERE NOT EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id)
+ORDER BY f.name;
+
+-- name: DeleteOrphanedFeeds :execrows
+DELETE FROM feeds f
+WHERE NOT EXISTS (SELECT 1 FROM feed_follows ff WHERE ff.feed_id = f.id);
+
+-- name: DeleteFeeds :execrows
+DELETE FROM feeds;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
+
+-- name: SetFeedURL :exec
+UPDATE feeds
+SET url = $2, updated_at = $3
+WHERE id = $1;
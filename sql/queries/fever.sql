@@ -0,0 +1,50 @@
+-- name: GetFeverFeeds :many
+SELECT f.id, f.seq, COALESCE(ff.display_name, f.name)::TEXT AS title, f.url, f.site_url, f.last_fetched_at,
+    (fi.data IS NOT NULL)::BOOLEAN AS has_icon
+FROM feed_follows ff
+    JOIN feeds f ON f.id = ff.feed_id
+    LEFT JOIN feed_icons fi ON fi.feed_id = f.id
+WHERE ff.user_id = $1
+ORDER BY title;
+
+-- name: GetFeverFavicons :many
+SELECT f.seq, fi.mime_type, fi.data
+FROM feed_follows ff
+    JOIN feeds f ON f.id = ff.feed_id
+    JOIN feed_icons fi ON fi.feed_id = f.id
+WHERE ff.user_id = $1 AND fi.data IS NOT NULL;
+
+-- name: CountFeverItems :one
+SELECT COUNT(*)
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+WHERE ff.user_id = $1;
+
+-- name: GetFeverItems :many
+SELECT p.seq, f.seq AS feed_seq, p.title, p.url, p.description, p.published_at, ps.read_at, ps.starred_at
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+    LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = sqlc.arg('user_id')
+    AND (sqlc.narg('since_id')::BIGINT IS NULL OR p.seq > sqlc.narg('since_id'))
+    AND (sqlc.narg('max_id')::BIGINT IS NULL OR p.seq < sqlc.narg('max_id'))
+    AND (sqlc.narg('with_ids')::BIGINT[] IS NULL OR p.seq = ANY(sqlc.narg('with_ids')::BIGINT[]))
+ORDER BY CASE WHEN sqlc.narg('max_id')::BIGINT IS NULL THEN p.seq END ASC, p.seq DESC
+LIMIT 50;
+
+-- name: GetFeverUnreadItemIDs :many
+SELECT p.seq
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1 AND ps.read_at IS NULL
+ORDER BY p.seq;
+
+-- name: GetFeverSavedItemIDs :many
+SELECT p.seq
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1 AND ps.starred_at IS NOT NULL
+ORDER BY p.seq;
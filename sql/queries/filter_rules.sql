@@ -0,0 +1,41 @@
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules(id, created_at, user_id, feed_id, title_regex, keyword, action)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetFilterRulesForUser :many
+SELECT r.*, f.name AS feed_name, f.url AS feed_url
+FROM filter_rules r
+    LEFT JOIN feeds f ON f.id = r.feed_id
+WHERE r.user_id = $1
+ORDER BY r.created_at;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE user_id = $1 AND id = $2;
+
+-- name: ApplyFilterRules :execrows
+INSERT INTO post_states(user_id, post_id, read_at, starred_at)
+SELECT ff.user_id, p.id,
+    CASE WHEN bool_or(r.action = 'mark-read') THEN sqlc.arg('applied_at')::TIMESTAMP END,
+    CASE WHEN bool_or(r.action = 'star') THEN sqlc.arg('applied_at')::TIMESTAMP END
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN filter_rules r ON r.user_id = ff.user_id
+WHERE p.id = ANY(sqlc.arg('post_ids')::UUID[])
+    AND r.action IN ('star', 'mark-read')
+    AND (r.feed_id IS NULL OR r.feed_id = p.feed_id)
+    AND (r.title_regex IS NULL OR p.title ~ r.title_regex)
+    AND (r.keyword IS NULL OR strpos(lower(p.title || ' ' || COALESCE(p.description, '')), lower(r.keyword)) > 0)
+GROUP BY ff.user_id, p.id
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, EXCLUDED.read_at),
+    starred_at = COALESCE(post_states.starred_at, EXCLUDED.starred_at);
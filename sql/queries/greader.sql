@@ -0,0 +1,33 @@
+-- name: GetGReaderItems :many
+SELECT p.seq, p.title, p.url, p.description, p.published_at, p.created_at,
+    f.seq AS feed_seq, COALESCE(ff.display_name, f.name)::TEXT AS feed_title, f.site_url AS feed_site_url,
+    ps.read_at, ps.starred_at
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+    LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = sqlc.arg('user_id')
+    AND (sqlc.narg('feed_seq')::BIGINT IS NULL OR f.seq = sqlc.narg('feed_seq'))
+    AND (sqlc.narg('tag')::TEXT IS NULL OR EXISTS (
+	SELECT 1
+	FROM feed_tags t
+	WHERE t.user_id = ff.user_id AND t.feed_id = p.feed_id AND t.name = sqlc.narg('tag')
+    ))
+    AND (sqlc.narg('starred')::BOOLEAN IS NULL OR (ps.starred_at IS NOT NULL) = sqlc.narg('starred'))
+    AND (sqlc.narg('read')::BOOLEAN IS NULL OR (ps.read_at IS NOT NULL) = sqlc.narg('read'))
+    AND (sqlc.narg('with_ids')::BIGINT[] IS NULL OR p.seq = ANY(sqlc.narg('with_ids')::BIGINT[]))
+    AND (sqlc.narg('newer_than')::TIMESTAMP IS NULL OR p.published_at >= sqlc.narg('newer_than'))
+    AND (sqlc.narg('older_than')::TIMESTAMP IS NULL OR p.published_at < sqlc.narg('older_than'))
+ORDER BY CASE WHEN sqlc.arg('oldest_first')::BOOLEAN THEN p.published_at END ASC,
+    p.published_at DESC, p.seq DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: GetGReaderUnreadCounts :many
+SELECT f.seq, COUNT(*) AS count, MAX(p.published_at)::TIMESTAMP AS newest
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+    LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = $1 AND ps.read_at IS NULL
+GROUP BY f.seq;
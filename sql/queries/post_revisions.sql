@@ -0,0 +1,11 @@
+-- name: CreatePostRevision :exec
+INSERT INTO post_revisions(id, post_id, replaced_at, title, url, description, content_hash)
+SELECT sqlc.arg('id')::UUID, p.id, sqlc.arg('replaced_at')::TIMESTAMP, p.title, p.url, p.description, p.content_hash
+FROM posts p
+WHERE p.id = sqlc.arg('post_id');
+
+-- name: GetPostRevisions :many
+SELECT *
+FROM post_revisions
+WHERE post_id = $1
+ORDER BY replaced_at DESC;
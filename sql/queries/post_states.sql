@@ -0,0 +1,36 @@
+-- name: SetPostRead :exec
+INSERT INTO post_states(user_id, post_id, read_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = EXCLUDED.read_at;
+
+-- name: SetPostStarred :exec
+INSERT INTO post_states(user_id, post_id, starred_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred_at = EXCLUDED.starred_at;
+
+-- name: MarkPostsReadBefore :exec
+INSERT INTO post_states(user_id, post_id, read_at)
+SELECT ff.user_id, p.id, sqlc.arg('read_at')::TIMESTAMP
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+WHERE ff.user_id = sqlc.arg('user_id')
+    AND p.created_at < sqlc.arg('before')
+    AND (sqlc.narg('feed_seq')::BIGINT IS NULL OR f.seq = sqlc.narg('feed_seq'))
+    AND (sqlc.narg('tag')::TEXT IS NULL OR EXISTS (
+	SELECT 1
+	FROM feed_tags t
+	WHERE t.user_id = ff.user_id AND t.feed_id = p.feed_id AND t.name = sqlc.narg('tag')
+    ))
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, EXCLUDED.read_at);
@@ -1,25 +1,103 @@
--- name: CreatePost :one
-INSERT INTO posts(id, created_at, updated_at, title, url, description, published_at, feed_id) 
-VALUES(
-    $1, 
-    $2, 
-    $3,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8
+-- name: CreatePosts :execrows
+INSERT INTO posts(id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash)
+SELECT i.id, sqlc.arg('created_at')::TIMESTAMP, sqlc.arg('created_at')::TIMESTAMP, i.title, i.url, NULLIF(i.description, ''),
+    i.published_at::TIMESTAMP, sqlc.arg('feed_id')::UUID, i.guid, i.content_hash
+FROM unnest(
+    sqlc.arg('ids')::UUID[],
+    sqlc.arg('titles')::TEXT[],
+    sqlc.arg('urls')::TEXT[],
+    sqlc.arg('descriptions')::TEXT[],
+    sqlc.arg('published_ats')::TEXT[],
+    sqlc.arg('guids')::TEXT[],
+    sqlc.arg('content_hashes')::TEXT[]
+) AS i(id, title, url, description, published_at, guid, content_hash)
+WHERE NOT EXISTS (
+    SELECT 1 FROM pruned_posts pp
+    WHERE pp.feed_id = sqlc.arg('feed_id') AND pp.guid = i.guid
 )
-ON CONFLICT (url) DO NOTHING
-RETURNING *;
+ON CONFLICT (feed_id, guid) DO NOTHING;
 
--- name: GetPostsForUser :many
-SELECT *
+-- name: AdoptPostGUIDs :exec
+UPDATE posts
+SET guid = i.guid
+FROM unnest(sqlc.arg('guids')::TEXT[], sqlc.arg('link_keys')::TEXT[]) AS i(guid, link_key)
+WHERE posts.feed_id = sqlc.arg('feed_id') AND posts.guid = i.link_key
+    AND NOT EXISTS (
+	SELECT 1 FROM posts o
+	WHERE o.feed_id = sqlc.arg('feed_id') AND o.guid = i.guid
+    );
+
+-- name: GetPostHashesByGUID :many
+SELECT id, guid, content_hash
 FROM posts
-WHERE feed_id IN (
-    SELECT feed_id
-    FROM feed_follows
-    WHERE user_id = $1
-)
-ORDER BY published_at DESC
-LIMIT $2;
+WHERE feed_id = $1 AND guid = ANY(sqlc.arg('guids')::TEXT[]);
+
+-- name: UpdatePostContent :exec
+UPDATE posts
+SET title = $2, url = $3, description = $4, content_hash = $5, updated_at = $6
+WHERE id = $1;
+
+-- name: GetDuplicatePostFeeds :many
+SELECT COALESCE(ff.display_name, f.name)::TEXT AS feed_title
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+WHERE ff.user_id = $1 AND p.url = $2 AND p.id <> $3
+ORDER BY feed_title;
+
+-- name: GetPostsForUser :many
+SELECT p.*, COALESCE(ff.display_name, f.name)::TEXT AS feed_title, f.url AS feed_url,
+    ps.read_at, ps.starred_at,
+    EXISTS (
+	SELECT 1 FROM filter_rules r
+	WHERE r.user_id = ff.user_id AND r.action = 'highlight'
+	    AND (r.feed_id IS NULL OR r.feed_id = p.feed_id)
+	    AND (r.title_regex IS NULL OR p.title ~ r.title_regex)
+	    AND (r.keyword IS NULL OR strpos(lower(p.title || ' ' || COALESCE(p.description, '')), lower(r.keyword)) > 0)
+    ) AS highlighted
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+    JOIN feeds f ON f.id = p.feed_id
+    LEFT JOIN post_states ps ON ps.post_id = p.id AND ps.user_id = ff.user_id
+WHERE ff.user_id = sqlc.arg('user_id')
+    AND (sqlc.narg('feed_id')::UUID IS NULL OR p.feed_id = sqlc.narg('feed_id'))
+    AND (sqlc.narg('tag')::TEXT IS NULL OR EXISTS (
+	SELECT 1
+	FROM feed_tags t
+	WHERE t.user_id = ff.user_id AND t.feed_id = p.feed_id AND t.name = sqlc.narg('tag')
+    ))
+    AND (sqlc.narg('starred')::BOOLEAN IS NULL OR (ps.starred_at IS NOT NULL) = sqlc.narg('starred'))
+    AND (sqlc.narg('read')::BOOLEAN IS NULL OR (ps.read_at IS NOT NULL) = sqlc.narg('read'))
+    AND (sqlc.narg('id_prefix')::TEXT IS NULL OR p.id::TEXT LIKE sqlc.narg('id_prefix') || '%')
+    AND (sqlc.narg('url')::TEXT IS NULL OR p.url = sqlc.narg('url'))
+    AND (sqlc.narg('search')::TEXT IS NULL
+	OR strpos(lower(p.title), lower(sqlc.narg('search'))) > 0
+	OR strpos(lower(p.description), lower(sqlc.narg('search'))) > 0)
+    AND (sqlc.narg('updated')::BOOLEAN IS NULL
+	OR (ps.read_at IS NOT NULL AND p.updated_at > ps.read_at) = sqlc.narg('updated'))
+    AND (sqlc.arg('show_hidden')::BOOLEAN OR NOT EXISTS (
+	SELECT 1 FROM filter_rules r
+	WHERE r.user_id = ff.user_id AND r.action = 'hide'
+	    AND (r.feed_id IS NULL OR r.feed_id = p.feed_id)
+	    AND (r.title_regex IS NULL OR p.title ~ r.title_regex)
+	    AND (r.keyword IS NULL OR strpos(lower(p.title || ' ' || COALESCE(p.description, '')), lower(r.keyword)) > 0)
+    ))
+ORDER BY p.published_at DESC, p.seq DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: GetPostIDBySeq :one
+SELECT p.id
+FROM posts p
+    JOIN feed_follows ff ON ff.feed_id = p.feed_id
+WHERE ff.user_id = $1 AND p.seq = $2;
+
+-- name: GetPostURLs :many
+SELECT id, url
+FROM posts;
+
+-- name: SetPostURLs :exec
+UPDATE posts
+SET url = i.url
+FROM unnest(sqlc.arg('ids')::UUID[], sqlc.arg('urls')::TEXT[]) AS i(id, url)
+WHERE posts.id = i.id;
@@ -0,0 +1,100 @@
+-- name: GetFeedRetentions :many
+SELECT fr.*, f.name AS feed_name, f.url AS feed_url
+FROM feed_retention fr
+    JOIN feeds f ON f.id = fr.feed_id
+ORDER BY f.name;
+
+-- name: SetFeedRetention :exec
+INSERT INTO feed_retention(feed_id, updated_at, keep_posts, keep_days)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (feed_id) DO UPDATE
+SET updated_at = EXCLUDED.updated_at, keep_posts = EXCLUDED.keep_posts, keep_days = EXCLUDED.keep_days;
+
+-- name: GetRetentionDefault :one
+SELECT keep_posts, keep_days
+FROM retention_default;
+
+-- name: SetRetentionDefault :exec
+UPDATE retention_default
+SET updated_at = $1, keep_posts = $2, keep_days = $3;
+
+-- name: DeleteFeedRetention :execrows
+DELETE FROM feed_retention
+WHERE feed_id = $1;
+
+-- name: ForgetPrunedPosts :exec
+DELETE FROM pruned_posts
+WHERE feed_id = $1 AND NOT (guid = ANY(sqlc.arg('guids')::TEXT[]));
+
+-- name: CountPrunablePosts :many
+WITH ranked AS (
+    SELECT p.id, p.feed_id, p.published_at,
+	ROW_NUMBER() OVER (PARTITION BY p.feed_id ORDER BY p.published_at DESC, p.id) AS rank,
+	COALESCE(fr.keep_posts, rd.keep_posts, 0) AS keep_posts,
+	COALESCE(fr.keep_days, rd.keep_days, 0) AS keep_days
+    FROM posts p
+	LEFT JOIN feed_retention fr ON fr.feed_id = p.feed_id
+	LEFT JOIN retention_default rd ON true
+)
+SELECT r.feed_id, COUNT(*) AS count
+FROM ranked r
+WHERE (r.keep_posts > 0 OR r.keep_days > 0)
+    AND (r.keep_posts = 0 OR r.rank > r.keep_posts)
+    AND (r.keep_days = 0 OR r.published_at < CURRENT_TIMESTAMP - make_interval(days => r.keep_days))
+    AND NOT EXISTS (
+	SELECT 1 FROM post_states ps
+	WHERE ps.post_id = r.id AND ps.starred_at IS NOT NULL
+    )
+    AND NOT EXISTS (
+	SELECT 1 FROM feed_follows ff
+	WHERE ff.feed_id = r.feed_id AND NOT EXISTS (
+	    SELECT 1 FROM post_states ps
+	    WHERE ps.post_id = r.id AND ps.user_id = ff.user_id AND ps.read_at IS NOT NULL
+	)
+    )
+GROUP BY r.feed_id;
+
+-- name: PrunePosts :many
+WITH ranked AS (
+    SELECT p.id, p.feed_id, p.published_at,
+	ROW_NUMBER() OVER (PARTITION BY p.feed_id ORDER BY p.published_at DESC, p.id) AS rank,
+	COALESCE(fr.keep_posts, rd.keep_posts, 0) AS keep_posts,
+	COALESCE(fr.keep_days, rd.keep_days, 0) AS keep_days
+    FROM posts p
+	LEFT JOIN feed_retention fr ON fr.feed_id = p.feed_id
+	LEFT JOIN retention_default rd ON true
+), pruned AS (
+    DELETE FROM posts
+    WHERE posts.id IN (
+	SELECT r.id
+	FROM ranked r
+	WHERE (r.keep_posts > 0 OR r.keep_days > 0)
+	    AND (r.keep_posts = 0 OR r.rank > r.keep_posts)
+	    AND (r.keep_days = 0 OR r.published_at < CURRENT_TIMESTAMP - make_interval(days => r.keep_days))
+	    AND NOT EXISTS (
+		SELECT 1 FROM post_states ps
+		WHERE ps.post_id = r.id AND ps.starred_at IS NOT NULL
+	    )
+	    AND NOT EXISTS (
+		SELECT 1 FROM feed_follows ff
+		WHERE ff.feed_id = r.feed_id AND NOT EXISTS (
+		    SELECT 1 FROM post_states ps
+		    WHERE ps.post_id = r.id AND ps.user_id = ff.user_id AND ps.read_at IS NOT NULL
+		)
+	    )
+    )
+    RETURNING posts.feed_id, posts.guid
+), tombstones AS (
+    INSERT INTO pruned_posts(feed_id, guid, pruned_at)
+    SELECT feed_id, guid, CURRENT_TIMESTAMP
+    FROM pruned
+    ON CONFLICT (feed_id, guid) DO NOTHING
+)
+SELECT feed_id, COUNT(*) AS count
+FROM pruned
+GROUP BY feed_id;
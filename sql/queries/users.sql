@@ -1,10 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, name)
+INSERT INTO users (id, created_at, updated_at, name, password_hash, is_admin)
 VALUES (
     $1,
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    NOT EXISTS (SELECT 1 FROM users)
 )
 RETURNING *;
 
@@ -13,11 +15,23 @@ SELECT *
 FROM users 
 WHERE name = $1;
 
--- name: DeleteUsers :exec
+-- name: DeleteUsers :execrows
 DELETE FROM users;
 
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: GetUserDataCounts :one
+SELECT
+    (SELECT COUNT(*) FROM feed_follows WHERE feed_follows.user_id = sqlc.arg('user_id')::UUID) AS follows,
+    (SELECT COUNT(*) FROM feed_tags WHERE feed_tags.user_id = sqlc.arg('user_id')::UUID) AS tags,
+    (SELECT COUNT(*) FROM post_states WHERE post_states.user_id = sqlc.arg('user_id')::UUID) AS post_states,
+    (SELECT COUNT(*) FROM api_tokens WHERE api_tokens.user_id = sqlc.arg('user_id')::UUID) AS tokens,
+    (SELECT COUNT(*) FROM feeds WHERE feeds.user_id = sqlc.arg('user_id')::UUID) AS feeds;
+
 -- name: GetUsers :many
-SELECT name
+SELECT *
 FROM users;
 
 -- name: GetUserNameByID :one
@@ -25,3 +39,7 @@ SELECT name
 FROM users 
 WHERE  id = $1;
 
+-- name: SetUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+WHERE id = $1;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE feed_tags (
+    id 			UUID PRIMARY KEY,
+    created_at 		TIMESTAMP NOT NULL,
+    user_id		UUID NOT NULL,
+    feed_id 		UUID NOT NULL,
+    name		TEXT NOT NULL,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    UNIQUE (user_id, feed_id, name)
+);
+
+-- +goose Down
+DROP TABLE feed_tags;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE api_tokens (
+    id 			UUID PRIMARY KEY,
+    created_at 		TIMESTAMP NOT NULL,
+    user_id		UUID NOT NULL,
+    name		TEXT NOT NULL,
+    token_hash		TEXT UNIQUE NOT NULL,
+    last_used_at	TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE api_tokens;
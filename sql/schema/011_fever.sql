@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE feeds ADD COLUMN seq BIGSERIAL UNIQUE;
+ALTER TABLE posts ADD COLUMN seq BIGSERIAL UNIQUE;
+ALTER TABLE api_tokens ADD COLUMN fever_key TEXT UNIQUE;
+
+CREATE TABLE feed_icons (
+    feed_id		UUID PRIMARY KEY,
+    fetched_at		TIMESTAMP NOT NULL,
+    mime_type		TEXT,
+    data		BYTEA,
+
+    FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_icons;
+ALTER TABLE api_tokens DROP COLUMN fever_key;
+ALTER TABLE posts DROP COLUMN seq;
+ALTER TABLE feeds DROP COLUMN seq;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE feed_retention (
+    feed_id		UUID PRIMARY KEY,
+    updated_at		TIMESTAMP NOT NULL,
+    keep_posts		INTEGER,
+    keep_days		INTEGER,
+
+    FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE feed_retention;
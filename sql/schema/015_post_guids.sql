@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN guid TEXT;
+
+-- posts were deduplicated by url, key them the way an item without a guid is
+-- keyed now. agg swaps in the real guid the next time it sees the item
+UPDATE posts SET guid = 'link:' || encode(sha256(convert_to(url, 'UTF8')), 'hex');
+
+ALTER TABLE posts ALTER COLUMN guid SET NOT NULL;
+ALTER TABLE posts DROP CONSTRAINT posts_url_key;
+ALTER TABLE posts ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+CREATE INDEX posts_url_idx ON posts (url);
+
+-- +goose Down
+DROP INDEX posts_url_idx;
+ALTER TABLE posts DROP CONSTRAINT posts_feed_id_guid_key;
+DELETE FROM posts p
+WHERE EXISTS (
+    SELECT 1 FROM posts o
+    WHERE o.url = p.url AND (o.created_at, o.id) < (p.created_at, p.id)
+);
+ALTER TABLE posts ADD CONSTRAINT posts_url_key UNIQUE (url);
+ALTER TABLE posts DROP COLUMN guid;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE posts ADD COLUMN content_hash TEXT;
+UPDATE posts
+SET content_hash = encode(sha256(convert_to(title || E'\n' || url || E'\n' || COALESCE(description, ''), 'UTF8')), 'hex');
+ALTER TABLE posts ALTER COLUMN content_hash SET NOT NULL;
+
+CREATE TABLE post_revisions (
+    id			UUID PRIMARY KEY,
+    post_id		UUID NOT NULL,
+    replaced_at		TIMESTAMP NOT NULL,
+    title		TEXT NOT NULL,
+    url			TEXT NOT NULL,
+    description		TEXT,
+    content_hash	TEXT NOT NULL,
+
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+CREATE INDEX post_revisions_post_id_idx ON post_revisions (post_id);
+
+-- +goose Down
+DROP TABLE post_revisions;
+ALTER TABLE posts DROP COLUMN content_hash;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE filter_rules (
+    id			UUID PRIMARY KEY,
+    created_at		TIMESTAMP NOT NULL,
+    user_id		UUID NOT NULL,
+    feed_id		UUID,
+    title_regex		TEXT,
+    keyword		TEXT,
+    action		TEXT NOT NULL CHECK (action IN ('hide', 'highlight', 'star', 'mark-read')),
+
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE,
+    CHECK (title_regex IS NOT NULL OR keyword IS NOT NULL),
+    -- compiling the pattern here means a bad one can't break every listing
+    -- later; a bad pattern fails with invalid_regular_expression rather than
+    -- as a check violation
+    CONSTRAINT filter_rules_title_regex_check CHECK (title_regex IS NULL OR ('' ~ title_regex) IS NOT NULL)
+);
+CREATE INDEX filter_rules_user_id_idx ON filter_rules (user_id);
+
+-- +goose Down
+DROP TABLE filter_rules;
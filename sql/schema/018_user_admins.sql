@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+-- the oldest user who set a password since 012 already runs this install,
+-- with nobody like that the oldest user does. Later only the first user of
+-- an empty database becomes the admin, never whoever registers next
+UPDATE users
+SET is_admin = true
+WHERE id = (
+    SELECT id FROM users
+    ORDER BY password_hash IS NULL, created_at
+    LIMIT 1
+);
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN is_admin;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE api_tokens RENAME COLUMN fever_key TO fever_key_hash;
+-- login and web sessions never needed a Fever key
+UPDATE api_tokens
+SET fever_key_hash = NULL
+WHERE name = 'web' OR name LIKE 'cli@%';
+UPDATE api_tokens
+SET fever_key_hash = encode(sha256(convert_to(fever_key_hash, 'UTF8')), 'hex')
+WHERE fever_key_hash IS NOT NULL;
+
+-- +goose Down
+-- the keys can't be worked back out of their hashes, Fever clients need a
+-- new token after this
+UPDATE api_tokens SET fever_key_hash = NULL;
+ALTER TABLE api_tokens RENAME COLUMN fever_key_hash TO fever_key;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE pruned_posts (
+    feed_id		UUID NOT NULL,
+    guid		TEXT NOT NULL,
+    pruned_at		TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (feed_id, guid),
+    FOREIGN KEY (feed_id) REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE pruned_posts;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- the default retention sits next to the per-feed overrides, so every user
+-- running agg or prune applies the same one; there is only ever one row
+CREATE TABLE retention_default (
+    id			BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    updated_at		TIMESTAMP NOT NULL,
+    keep_posts		INTEGER NOT NULL DEFAULT 0,
+    keep_days		INTEGER NOT NULL DEFAULT 0
+);
+INSERT INTO retention_default (updated_at) VALUES (CURRENT_TIMESTAMP);
+
+-- +goose Down
+DROP TABLE retention_default;
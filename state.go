@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+
 	"github.com/colfarl/gator/internal/config"
 	"github.com/colfarl/gator/internal/database"
 )
@@ -8,18 +10,20 @@ import (
 type state struct {
 	CurrentState			*config.Config	
 	db						*database.Queries
+	conn					*sql.DB
 }
 
-func newState(c *config.Config, q *database.Queries) state {
+func newState(c *config.Config, conn *sql.DB, q *database.Queries) state {
 	return state{
 		CurrentState: c,
 		db: q,
+		conn: conn,
 	}
 }
 
-func (s *state) updateUser(newUser string) error {
+func (s *state) updateUser(newUser, token string) error {
 
-	err := s.CurrentState.SetUser(newUser)
+	err := s.CurrentState.SetUser(newUser, token)
 	if err != nil {
 		return err
 	}
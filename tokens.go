@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+const apiTokenPrefix = "gator_"
+
+// Only the sha256 of a token is stored, the token itself is shown once when
+// it is created. The same goes for the Fever key of tokens made for Fever.
+
+func newAPIToken() (string, error) {
+	buf := make([]byte, 32)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return apiTokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// feverKey is the api_key Fever clients send, md5 of "user:password" with the
+// token standing in for the password. It can only be worked out while the
+// token is still known, so its hash is stored next to the token's
+func feverKey(userName, token string) string {
+	sum := md5.Sum([]byte(userName + ":" + token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createAPIToken makes a token for user, with a Fever key only when fever is
+// set since the key is a credential of its own
+func createAPIToken(s *state, user database.User, name string, fever bool) (string, database.ApiToken, error) {
+
+	token, err := newAPIToken()
+	if err != nil {
+		return "", database.ApiToken{}, err
+	}
+
+	params := database.CreateAPITokenParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UserID: user.ID,
+		Name: name,
+		TokenHash: hashAPIToken(token),
+	}
+	if fever {
+		params.FeverKeyHash = sql.NullString{String: hashAPIToken(feverKey(user.Name.String, token)), Valid: true}
+	}
+
+	created, err := s.db.CreateAPIToken(context.Background(), params)
+	if err != nil {
+		return "", database.ApiToken{}, err
+	}
+	return token, created, nil
+}
+
+// userForAPIToken looks up the owner of token and records that it was used
+func userForAPIToken(s *state, token string) (database.User, error) {
+
+	hash := hashAPIToken(token)
+	user, err := s.db.GetUserByAPITokenHash(context.Background(), hash)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = s.db.TouchAPIToken(context.Background(), hash)
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func userForFeverKey(s *state, key string) (database.User, error) {
+
+	keyHash := sql.NullString{String: hashAPIToken(strings.ToLower(key)), Valid: key != ""}
+	user, err := s.db.GetUserByFeverKeyHash(context.Background(), keyHash)
+	if err != nil {
+		return database.User{}, err
+	}
+
+	err = s.db.TouchAPITokenByFeverKeyHash(context.Background(), keyHash)
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+func handlerTokenCreate(s *state, cmd command, user database.User) error {
+
+	fever := cmd.flagBool("fever")
+	token, created, err := createAPIToken(s, user, cmd.flagString("name"), fever)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created token %q for %s\n", created.Name, user.Name.String)
+	fmt.Println("it will not be shown again, send it as \"Authorization: Bearer <token>\":")
+	fmt.Println(token)
+	if fever {
+		fmt.Printf("Fever clients log in as %s with the token as the password\n", user.Name.String)
+	}
+	return nil
+}
+
+func handlerTokenList(s *state, cmd command, user database.User) error {
+
+	tokens, err := s.db.GetAPITokensForUser(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	var current string
+	if token := sessionToken(s); token != "" {
+		current = hashAPIToken(token)
+	}
+	var records []tokenRecord
+	for _, v := range tokens {
+		records = append(records, newTokenRecord(v, current))
+	}
+
+	return printListing(cmd.Output, records, func(records []tokenRecord) {
+		if len(records) == 0 {
+			fmt.Println("no tokens")
+			return
+		}
+		for _, v := range records {
+			lastUsed := "never used"
+			if v.LastUsedAt != nil {
+				lastUsed = "last used " + v.LastUsedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Printf(" * %s  %s  created %s, %s", v.ID.String()[:8], v.Name, v.CreatedAt.Format("2006-01-02"), lastUsed)
+			if v.Fever {
+				fmt.Print(" (fever)")
+			}
+			if v.Current {
+				fmt.Print(" (current)")
+			}
+			fmt.Println()
+		}
+	})
+}
+
+// findAPIToken matches a token by its name or by a prefix of its id, at least
+// four characters so a stray letter can't revoke the wrong one
+func findAPIToken(tokens []database.ApiToken, ref string) (database.ApiToken, error) {
+
+	var matches []database.ApiToken
+	for _, v := range tokens {
+		if v.Name == ref || (len(ref) >= 4 && strings.HasPrefix(v.ID.String(), strings.ToLower(ref))) {
+			matches = append(matches, v)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return database.ApiToken{}, fmt.Errorf("no token named or with an id starting %q", ref)
+	case 1:
+		return matches[0], nil
+	default:
+		return database.ApiToken{}, fmt.Errorf("%q matches %d tokens, use more of the id from gator token list", ref, len(matches))
+	}
+}
+
+func handlerTokenRevoke(s *state, cmd command, user database.User) error {
+
+	tokens, err := s.db.GetAPITokensForUser(context.Background(), user.ID)
+	if err != nil {
+		return err
+	}
+
+	token, err := findAPIToken(tokens, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.DeleteAPIToken(context.Background(), database.DeleteAPITokenParams{
+		UserID: user.ID,
+		ID: token.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// revoking the token gator login saved is the same as logging out
+	if s.CurrentState.CurrentUserToken != "" && hashAPIToken(s.CurrentState.CurrentUserToken) == token.TokenHash {
+		err = s.updateUser("", "")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("revoked token %q, this was your session so you are now logged out\n", token.Name)
+		return nil
+	}
+
+	fmt.Printf("revoked token %q\n", token.Name)
+	return nil
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+const (
+	tuiPostLimit = 200
+
+	focusSources = 0
+	focusPosts   = 1
+
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiReverse = "\x1b[7m"
+)
+
+const tuiHelp = "j/k move  tab switch pane  n/p next/prev post  space/b scroll  m read  s star  o open  r refresh  q quit"
+
+// tuiSource is one entry of the sidebar, the filters are passed straight
+// through to GetPostsForUser
+type tuiSource struct {
+	Label   string
+	FeedID  uuid.NullUUID
+	Tag     sql.NullString
+	Starred sql.NullBool
+	Read    sql.NullBool
+}
+
+type tui struct {
+	s         *state
+	user      database.User
+	sources   []tuiSource
+	source    int
+	sourceTop int
+	posts     []database.GetPostsForUserRow
+	post      int
+	postTop   int
+	scroll    int
+	focus     int
+	status    string
+	width     int
+	height    int
+	out       *bufio.Writer
+}
+
+func handlerTUI(s *state, cmd command, user database.User) error {
+
+	inFd := int(os.Stdin.Fd())
+	outFd := int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return fmt.Errorf("tui needs an interactive terminal")
+	}
+
+	t := &tui{
+		s: s,
+		user: user,
+		focus: focusPosts,
+		status: tuiHelp,
+		out: bufio.NewWriter(os.Stdout),
+	}
+
+	err := t.loadSources()
+	if err != nil {
+		return err
+	}
+	err = t.loadPosts()
+	if err != nil {
+		return err
+	}
+
+	oldState, err := term.MakeRaw(inFd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(inFd, oldState)
+
+	// alternate screen and hidden cursor, undone on the way out
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string)
+	go readKeys(os.Stdin, keys)
+
+	resize := time.NewTicker(250 * time.Millisecond)
+	defer resize.Stop()
+
+	t.draw()
+	for {
+		select {
+		case key, ok := <-keys:
+			if !ok || t.handleKey(key) {
+				return nil
+			}
+			t.draw()
+		case <-resize.C:
+			w, h, err := term.GetSize(outFd)
+			if err == nil && (w != t.width || h != t.height) {
+				t.draw()
+			}
+		}
+	}
+}
+
+// readKeys turns raw terminal input into key names: single characters, or
+// "up", "down", "left", "right", "pgup", "pgdn", "tab", "enter", "ctrl-c"
+func readKeys(in *os.File, keys chan<- string) {
+
+	defer close(keys)
+	buf := make([]byte, 64)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+
+		input := buf[:n]
+		for len(input) > 0 {
+			if input[0] == 0x1b && len(input) >= 3 && input[1] == '[' {
+				size := 3
+				key := ""
+				switch input[2] {
+				case 'A':
+					key = "up"
+				case 'B':
+					key = "down"
+				case 'C':
+					key = "right"
+				case 'D':
+					key = "left"
+				case '5', '6':
+					if len(input) >= 4 && input[3] == '~' {
+						size = 4
+						key = map[byte]string{'5': "pgup", '6': "pgdn"}[input[2]]
+					}
+				}
+				input = input[size:]
+				if key != "" {
+					keys <- key
+				}
+				continue
+			}
+
+			r, size := utf8.DecodeRune(input)
+			input = input[size:]
+			switch r {
+			case 3:
+				keys <- "ctrl-c"
+			case '\t':
+				keys <- "tab"
+			case '\r', '\n':
+				keys <- "enter"
+			default:
+				keys <- string(r)
+			}
+		}
+	}
+}
+
+// ======== Data ========
+
+func (t *tui) loadSources() error {
+
+	follows, err := t.s.db.GetFeedFollowsForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return err
+	}
+	sort.Slice(follows, func(i, j int) bool {
+		return strings.ToLower(follows[i].FeedTitle) < strings.ToLower(follows[j].FeedTitle)
+	})
+
+	feedTags, err := t.s.db.GetFeedTagsForUser(context.Background(), t.user.ID)
+	if err != nil {
+		return err
+	}
+	followed := make(map[uuid.UUID]bool)
+	for _, f := range follows {
+		followed[f.FeedID] = true
+	}
+	var tags []string
+	for _, v := range feedTags {
+		if followed[v.FeedID] {
+			tags = append(tags, v.Name)
+		}
+	}
+
+	t.sources = []tuiSource{
+		{Label: "All"},
+		{Label: "Unread", Read: sql.NullBool{Bool: false, Valid: true}},
+		{Label: "Starred", Starred: sql.NullBool{Bool: true, Valid: true}},
+	}
+	for _, tag := range uniqueTags(tags) {
+		t.sources = append(t.sources, tuiSource{
+			Label: "# " + tag,
+			Tag: sql.NullString{String: tag, Valid: true},
+		})
+	}
+	for _, f := range follows {
+		t.sources = append(t.sources, tuiSource{
+			Label: f.FeedTitle,
+			FeedID: uuid.NullUUID{UUID: f.FeedID, Valid: true},
+		})
+	}
+
+	if t.source >= len(t.sources) {
+		t.source = len(t.sources) - 1
+	}
+	return nil
+}
+
+func (t *tui) loadPosts() error {
+
+	src := t.sources[t.source]
+	params := database.GetPostsForUserParams{
+		UserID: t.user.ID,
+		FeedID: src.FeedID,
+		Tag: src.Tag,
+		Starred: src.Starred,
+		Read: src.Read,
+		Limit: tuiPostLimit,
+	}
+
+	posts, err := t.s.db.GetPostsForUser(context.Background(), params)
+	if err != nil {
+		return err
+	}
+
+	// keep the cursor on the same post across refreshes when it is still there
+	var selected uuid.UUID
+	if t.post < len(t.posts) {
+		selected = t.posts[t.post].ID
+	}
+	t.posts = posts
+	t.post = 0
+	for i, p := range posts {
+		if p.ID == selected {
+			t.post = i
+		}
+	}
+	t.scroll = 0
+	return nil
+}
+
+func (t *tui) currentPost() (*database.GetPostsForUserRow, bool) {
+	if t.post >= len(t.posts) {
+		return nil, false
+	}
+	return &t.posts[t.post], true
+}
+
+func (t *tui) toggleRead() error {
+
+	p, ok := t.currentPost()
+	if !ok {
+		return nil
+	}
+
+	readAt := sql.NullTime{}
+	if !p.ReadAt.Valid {
+		readAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return t.setRead(p, readAt)
+}
+
+func (t *tui) setRead(p *database.GetPostsForUserRow, readAt sql.NullTime) error {
+
+	err := t.s.db.SetPostRead(context.Background(), database.SetPostReadParams{
+		UserID: t.user.ID,
+		PostID: p.ID,
+		ReadAt: readAt,
+	})
+	if err != nil {
+		return err
+	}
+	p.ReadAt = readAt
+	return nil
+}
+
+func (t *tui) toggleStar() error {
+
+	p, ok := t.currentPost()
+	if !ok {
+		return nil
+	}
+
+	starredAt := sql.NullTime{}
+	if !p.StarredAt.Valid {
+		starredAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	err := t.s.db.SetPostStarred(context.Background(), database.SetPostStarredParams{
+		UserID: t.user.ID,
+		PostID: p.ID,
+		StarredAt: starredAt,
+	})
+	if err != nil {
+		return err
+	}
+	p.StarredAt = starredAt
+	return nil
+}
+
+func (t *tui) openPost() error {
+
+	p, ok := t.currentPost()
+	if !ok {
+		return nil
+	}
+
+	err := openURL(p.Url)
+	if err != nil {
+		return err
+	}
+	if p.ReadAt.Valid {
+		return nil
+	}
+	return t.setRead(p, sql.NullTime{Time: time.Now(), Valid: true})
+}
+
+// ======== Input ========
+
+func (t *tui) movePost(delta int) {
+	t.post = clamp(t.post+delta, 0, len(t.posts)-1)
+	t.scroll = 0
+}
+
+func (t *tui) moveSource(delta int) error {
+	next := clamp(t.source+delta, 0, len(t.sources)-1)
+	if next == t.source {
+		return nil
+	}
+	t.source = next
+	t.post = 0
+	t.postTop = 0
+	return t.loadPosts()
+}
+
+// handleKey applies one key press and reports whether the reader should exit
+func (t *tui) handleKey(key string) bool {
+
+	var err error
+	t.status = tuiHelp
+	switch key {
+	case "q", "ctrl-c":
+		return true
+	case "tab":
+		t.focus = 1 - t.focus
+	case "h", "left":
+		t.focus = focusSources
+	case "l", "right", "enter":
+		t.focus = focusPosts
+	case "j", "down":
+		if t.focus == focusSources {
+			err = t.moveSource(1)
+		} else {
+			t.movePost(1)
+		}
+	case "k", "up":
+		if t.focus == focusSources {
+			err = t.moveSource(-1)
+		} else {
+			t.movePost(-1)
+		}
+	case "n":
+		t.movePost(1)
+	case "p":
+		t.movePost(-1)
+	case " ", "pgdn":
+		t.scroll += t.previewHeight() - 1
+	case "b", "pgup":
+		t.scroll = max(0, t.scroll-(t.previewHeight()-1))
+	case "m":
+		err = t.toggleRead()
+	case "s":
+		err = t.toggleStar()
+	case "o":
+		err = t.openPost()
+		if err == nil {
+			t.status = "opened in browser"
+		}
+	case "r":
+		err = t.loadSources()
+		if err == nil {
+			err = t.loadPosts()
+		}
+		if err == nil {
+			t.status = fmt.Sprintf("refreshed at %s", time.Now().Format(time.Kitchen))
+		}
+	}
+
+	if err != nil {
+		t.status = "error: " + err.Error()
+	}
+	return false
+}
+
+// ======== Drawing ========
+
+func clamp(v, low, high int) int {
+	if v > high {
+		v = high
+	}
+	if v < low {
+		v = low
+	}
+	return v
+}
+
+// fit cuts or pads s to exactly width cells
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, s)
+
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		runes := []rune(s)
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+func (t *tui) sidebarWidth() int {
+	return clamp(t.width/4, 12, 30)
+}
+
+func (t *tui) listHeight() int {
+	return clamp((t.height-3)/3, 3, 15)
+}
+
+func (t *tui) previewHeight() int {
+	return max(1, t.height-3-t.listHeight())
+}
+
+func (t *tui) previewLines(width int) []string {
+
+	p, ok := t.currentPost()
+	if !ok {
+		return []string{"", "  nothing here yet, try 'gator agg' or another feed"}
+	}
+
+	lines := []string{
+		ansiBold + fit(p.Title, width) + ansiReset,
+		ansiDim + fit(fmt.Sprintf("%s · %s", p.FeedTitle, p.PublishedAt.Format("Mon Jan 2 2006 15:04")), width) + ansiReset,
+		ansiDim + fit(p.Url, width) + ansiReset,
+		"",
+	}
+	for _, v := range strings.Split(renderHTML(p.Description.String, width, false), "\n") {
+		lines = append(lines, fit(v, width))
+	}
+	return lines
+}
+
+func (t *tui) postLine(p database.GetPostsForUserRow, width int) string {
+
+	marks := "  "
+	if !p.ReadAt.Valid {
+		marks = "● "
+	}
+	if p.StarredAt.Valid {
+		marks = marks[:len(marks)-1] + "★"
+	}
+
+	// highlight filter rules
+	title := p.Title
+	if p.Highlighted {
+		title = "» " + title
+	}
+
+	right := fmt.Sprintf("  %s  %s", fit(p.FeedTitle, 16), p.PublishedAt.Format("Jan 02"))
+	leftWidth := width - utf8.RuneCountInString(right) - 2
+	if leftWidth < 10 {
+		return fit(marks+" "+title, width)
+	}
+	return fit(marks+" "+fit(title, leftWidth)+right, width)
+}
+
+func highlight(line string, selected, focused bool) string {
+	if !selected {
+		return line
+	}
+	if focused {
+		return ansiReverse + line + ansiReset
+	}
+	return ansiBold + line + ansiReset
+}
+
+func (t *tui) draw() {
+
+	w, h, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		w, h = 80, 24
+	}
+	t.width, t.height = w, h
+
+	sw := t.sidebarWidth()
+	rw := max(1, w-sw-1)
+	bodyHeight := max(1, h-2)
+	listHeight := t.listHeight()
+
+	if t.source < t.sourceTop {
+		t.sourceTop = t.source
+	}
+	if t.source >= t.sourceTop+bodyHeight {
+		t.sourceTop = t.source - bodyHeight + 1
+	}
+	if t.post < t.postTop {
+		t.postTop = t.post
+	}
+	if t.post >= t.postTop+listHeight {
+		t.postTop = t.post - listHeight + 1
+	}
+
+	preview := t.previewLines(rw)
+	t.scroll = clamp(t.scroll, 0, max(0, len(preview)-t.previewHeight()))
+
+	var right []string
+	for i := 0; i < listHeight; i++ {
+		idx := t.postTop + i
+		if idx >= len(t.posts) {
+			right = append(right, fit("", rw))
+			continue
+		}
+		right = append(right, highlight(t.postLine(t.posts[idx], rw), idx == t.post, t.focus == focusPosts))
+	}
+	right = append(right, strings.Repeat("─", rw))
+	for i := 0; i < t.previewHeight(); i++ {
+		idx := t.scroll + i
+		if idx >= len(preview) {
+			right = append(right, fit("", rw))
+			continue
+		}
+		right = append(right, preview[idx])
+	}
+
+	out := t.out
+	out.WriteString("\x1b[H")
+	title := fmt.Sprintf(" gator · %s · %s (%d)", t.user.Name.String, t.sources[t.source].Label, len(t.posts))
+	out.WriteString(ansiReverse + fit(title, w) + ansiReset + "\r\n")
+
+	for i := 0; i < bodyHeight; i++ {
+		left := fit("", sw)
+		idx := t.sourceTop + i
+		if idx < len(t.sources) {
+			left = highlight(fit(" "+t.sources[idx].Label, sw), idx == t.source, t.focus == focusSources)
+		}
+		line := ""
+		if i < len(right) {
+			line = right[i]
+		}
+		out.WriteString(left + "│" + line + "\x1b[K\r\n")
+	}
+
+	out.WriteString(ansiDim + fit(" "+t.status, w) + ansiReset)
+	out.Flush()
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/colfarl/gator/internal/database"
+	"golang.org/x/net/html"
+)
+
+// Feeds and posts are stored under a canonical url so the same address typed
+// or linked slightly differently is one row. The scheme is kept as given,
+// http and https can serve different things.
+
+// trackingParams are dropped from query strings, along with any utm_*
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"igshid":  true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+	"mkt_tok": true,
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "utm_") || trackingParams[name]
+}
+
+// canonicalURL resolves raw against base (which may be nil), lowercases the
+// scheme and host, drops default ports, tracking parameters and a trailing
+// slash. Anything that isn't an absolute http(s) url comes back as is
+func canonicalURL(raw string, base *url.URL) string {
+
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return raw
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	port := u.Port()
+	if port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if u.Path == "" {
+		u.Path = "/"
+	} else if len(u.Path) > 1 && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+		if u.Path == "" {
+			u.Path, u.RawPath = "/", ""
+		}
+	}
+
+	// filtered by hand rather than through url.Values, which would reorder
+	// the parameters that are left
+	if u.RawQuery != "" {
+		var kept []string
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			name, _, _ := strings.Cut(pair, "=")
+			if pair == "" || isTrackingParam(name) {
+				continue
+			}
+			kept = append(kept, pair)
+		}
+		u.RawQuery = strings.Join(kept, "&")
+	}
+	u.ForceQuery = false
+
+	return u.String()
+}
+
+// lookupFeed finds a feed by a url as typed or linked: under its canonical
+// form first, then exactly as given for feeds stored before urls were
+// canonicalized
+func lookupFeed(ctx context.Context, q *database.Queries, raw string) (database.Feed, error) {
+
+	canonical := canonicalURL(raw, nil)
+	feed, err := q.GetFeedByURL(ctx, sql.NullString{String: canonical, Valid: canonical != ""})
+	if errors.Is(err, sql.ErrNoRows) && canonical != raw {
+		return q.GetFeedByURL(ctx, sql.NullString{String: raw, Valid: raw != ""})
+	}
+	return feed, err
+}
+
+// withBase applies an xml:base attribute on top of the base inherited from the
+// enclosing element, a relative xml:base being relative to that base
+func withBase(xmlBase string, base *url.URL) *url.URL {
+
+	xmlBase = strings.TrimSpace(xmlBase)
+	if xmlBase == "" {
+		return base
+	}
+	u, err := url.Parse(xmlBase)
+	if err != nil {
+		return base
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if !u.IsAbs() {
+		return base
+	}
+	return u
+}
+
+// feedBase is what relative links in a feed's items resolve against: the
+// channel's or <rss>'s xml:base, else the channel's <link>, else the feed url.
+// Items can set an xml:base of their own on top. The link is used as the feed
+// sent it, canonicalURL would drop the trailing slash of a directory-style
+// link and with it a level of the path
+func feedBase(feed *RSSFeed, feedURL *url.URL) *url.URL {
+
+	base := withBase(feed.Channel.Base, withBase(feed.Base, feedURL))
+	if base != feedURL {
+		return base
+	}
+	return withBase(feed.Channel.Link, base)
+}
+
+// linkAttrs are the attributes of item content holding a single url
+var linkAttrs = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"poster": true,
+}
+
+// resolveLink leaves in-page anchors alone, they point into the item itself
+// rather than anywhere on the site
+func resolveLink(raw string, base *url.URL) string {
+
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return raw
+	}
+	u, err := url.Parse(trimmed)
+	if err != nil || u.IsAbs() {
+		return raw
+	}
+	return base.ResolveReference(u).String()
+}
+
+// resolveSrcset resolves each candidate of a srcset, "url [descriptor], ..."
+func resolveSrcset(raw string, base *url.URL) string {
+
+	changed := false
+	candidates := strings.Split(raw, ",")
+	for i, v := range candidates {
+		fields := strings.Fields(v)
+		if len(fields) == 0 {
+			continue
+		}
+		resolved := resolveLink(fields[0], base)
+		if resolved != fields[0] {
+			fields[0], changed = resolved, true
+		}
+		candidates[i] = strings.Join(fields, " ")
+	}
+	if !changed {
+		return raw
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// resolveContentLinks rewrites relative href, src, poster and srcset
+// attributes in an item's html against base. Tags without relative links are
+// copied through byte for byte, so markup the feed sent is otherwise untouched
+func resolveContentLinks(content string, base *url.URL) string {
+
+	if base == nil || !strings.Contains(content, "=") {
+		return content
+	}
+
+	var out strings.Builder
+	z := html.NewTokenizer(strings.NewReader(content))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return content
+			}
+			return out.String()
+		}
+		// Raw is only valid until the tokenizer is asked for anything else
+		raw := string(z.Raw())
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			out.WriteString(raw)
+			continue
+		}
+
+		token := z.Token()
+		changed := false
+		for i, attr := range token.Attr {
+			resolved := attr.Val
+			switch {
+			case linkAttrs[attr.Key]:
+				resolved = resolveLink(attr.Val, base)
+			case attr.Key == "srcset":
+				resolved = resolveSrcset(attr.Val, base)
+			}
+			if resolved != attr.Val {
+				token.Attr[i].Val = resolved
+				changed = true
+			}
+		}
+		if !changed {
+			out.WriteString(raw)
+			continue
+		}
+		out.WriteString(token.String())
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalURL(t *testing.T) {
+
+	base, _ := url.Parse("https://example.com/blog/")
+	tests := []struct {
+		name string
+		raw  string
+		base *url.URL
+		want string
+	}{
+		{"empty", "", nil, ""},
+		{"surrounding space", "  https://example.com/feed  ", nil, "https://example.com/feed"},
+		{"scheme and host case", "HTTPS://Example.COM/Feed", nil, "https://example.com/Feed"},
+		{"trailing dot in host", "https://example.com./feed", nil, "https://example.com/feed"},
+		{"default http port", "http://example.com:80/feed", nil, "http://example.com/feed"},
+		{"default https port", "https://example.com:443/feed", nil, "https://example.com/feed"},
+		{"other port kept", "https://example.com:8443/feed", nil, "https://example.com:8443/feed"},
+		{"http port on https kept", "https://example.com:80/feed", nil, "https://example.com:80/feed"},
+		{"empty path", "https://example.com", nil, "https://example.com/"},
+		{"root path", "https://example.com/", nil, "https://example.com/"},
+		{"trailing slash", "https://example.com/feed/", nil, "https://example.com/feed"},
+		{"trailing slashes", "https://example.com/feed//", nil, "https://example.com/feed"},
+		{"utm params", "https://example.com/a?utm_source=x&utm_Medium=y", nil, "https://example.com/a"},
+		{"fbclid", "https://example.com/a?fbclid=abc", nil, "https://example.com/a"},
+		{"tracking mixed in", "https://example.com/a?b=2&gclid=x&a=1", nil, "https://example.com/a?b=2&a=1"},
+		{"empty query", "https://example.com/a?", nil, "https://example.com/a"},
+		{"fragment kept", "https://example.com/a#part", nil, "https://example.com/a#part"},
+		{"ipv6 host", "http://[2001:DB8::1]/feed", nil, "http://[2001:db8::1]/feed"},
+		{"ipv6 default port", "http://[2001:db8::1]:80/feed", nil, "http://[2001:db8::1]/feed"},
+		{"ipv6 other port", "http://[::1]:8080/feed", nil, "http://[::1]:8080/feed"},
+		{"not http", "mailto:someone@example.com", nil, "mailto:someone@example.com"},
+		{"relative without base", "/2024/05/post", nil, "/2024/05/post"},
+		{"relative to base", "2024/05/post", base, "https://example.com/blog/2024/05/post"},
+		{"root relative to base", "/2024/05/post/", base, "https://example.com/2024/05/post"},
+		{"protocol relative", "//Cdn.Example.com/a", base, "https://cdn.example.com/a"},
+		{"absolute ignores base", "http://other.org/x", base, "http://other.org/x"},
+	}
+
+	for _, tt := range tests {
+		got := canonicalURL(tt.raw, tt.base)
+		if got != tt.want {
+			t.Errorf("%s: canonicalURL(%q) = %q, want %q", tt.name, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestWithBase(t *testing.T) {
+
+	feed, _ := url.Parse("https://example.com/feeds/rss.xml")
+	tests := []struct {
+		name  string
+		bases []string
+		want  string
+	}{
+		{"none", nil, "https://example.com/feeds/rss.xml"},
+		{"empty", []string{"", ""}, "https://example.com/feeds/rss.xml"},
+		{"absolute", []string{"https://cdn.example.org/"}, "https://cdn.example.org/"},
+		{"relative to feed", []string{"/blog/"}, "https://example.com/blog/"},
+		{"nested relative", []string{"/blog/", "2024/"}, "https://example.com/blog/2024/"},
+		{"nested absolute resets", []string{"/blog/", "https://other.org/x/", "y/"}, "https://other.org/x/y/"},
+		{"empty keeps outer", []string{"/blog/", ""}, "https://example.com/blog/"},
+	}
+
+	for _, tt := range tests {
+		base := feed
+		for _, v := range tt.bases {
+			base = withBase(v, base)
+		}
+		if got := base.String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveContentLinks(t *testing.T) {
+
+	base, _ := url.Parse("https://example.com/blog/post/")
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain text", "no links here", "no links here"},
+		{"relative href", `<a href="next">next</a>`, `<a href="https://example.com/blog/post/next">next</a>`},
+		{"root relative src", `<img src="/img/a.png">`, `<img src="https://example.com/img/a.png">`},
+		{"poster", `<video poster="p.jpg"></video>`, `<video poster="https://example.com/blog/post/p.jpg"></video>`},
+		{"anchor untouched", `<a href="#note-1">1</a>`, `<a href="#note-1">1</a>`},
+		{"empty href untouched", `<a href="">x</a>`, `<a href="">x</a>`},
+		{"absolute copied byte for byte", `<A HREF='https://x.org/a'  class=big>x</A>`, `<A HREF='https://x.org/a'  class=big>x</A>`},
+		{"other markup copied byte for byte", "<p CLASS=x>a &amp; b<br></p><!-- c -->", "<p CLASS=x>a &amp; b<br></p><!-- c -->"},
+		{"srcset", `<img srcset="a.png 1x, /b.png 2x">`, `<img srcset="https://example.com/blog/post/a.png 1x, https://example.com/b.png 2x">`},
+		{"srcset mixed", `<img srcset="https://cdn.org/a.png 480w,b.png 800w">`, `<img srcset="https://cdn.org/a.png 480w, https://example.com/blog/post/b.png 800w">`},
+		{"absolute srcset copied byte for byte", `<img srcset="https://cdn.org/a.png 1x,https://cdn.org/b.png 2x">`, `<img srcset="https://cdn.org/a.png 1x,https://cdn.org/b.png 2x">`},
+		{"other attrs left", `<a href="x" title="/y">t</a>`, `<a href="https://example.com/blog/post/x" title="/y">t</a>`},
+	}
+
+	for _, tt := range tests {
+		if got := resolveContentLinks(tt.content, base); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	content := `<a href="x">x</a>`
+	if got := resolveContentLinks(content, nil); got != content {
+		t.Errorf("nil base: got %q, want %q", got, content)
+	}
+}
+
+func TestRSSFeedXMLBase(t *testing.T) {
+
+	data := `<rss version="2.0" xml:base="https://example.com/">
+<channel xml:base="blog/"><title>t</title>
+<item xml:base="2024/"><link>post</link></item>
+</channel></rss>`
+
+	var feed RSSFeed
+	err := xml.Unmarshal([]byte(data), &feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := feedBase(&feed, nil)
+	base = withBase(feed.Channel.Item[0].Base, base)
+	got := canonicalURL(feed.Channel.Item[0].Link, base)
+	if want := "https://example.com/blog/2024/post"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFeedBase(t *testing.T) {
+
+	feedURL, _ := url.Parse("https://feeds.example.com/blog/rss")
+	tests := []struct {
+		name        string
+		rootBase    string
+		channelBase string
+		link        string
+		want        string
+	}{
+		{"feed url", "", "", "", "https://feeds.example.com/blog/rss"},
+		{"directory-style link", "", "", "https://example.com/blog/", "https://example.com/blog/"},
+		{"file-style link", "", "", "https://example.com/blog", "https://example.com/blog"},
+		{"relative link", "", "", "/blog/", "https://feeds.example.com/blog/"},
+		{"channel xml:base over link", "", "https://cdn.example.com/a/", "https://example.com/blog/", "https://cdn.example.com/a/"},
+		{"root xml:base over link", "https://cdn.example.com/a/", "", "https://example.com/blog/", "https://cdn.example.com/a/"},
+		{"channel xml:base on root", "https://cdn.example.com/a/", "b/", "", "https://cdn.example.com/a/b/"},
+	}
+
+	for _, tt := range tests {
+		var feed RSSFeed
+		feed.Base, feed.Channel.Base, feed.Channel.Link = tt.rootBase, tt.channelBase, tt.link
+		if got := feedBase(&feed, feedURL).String(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDirectoryChannelLink(t *testing.T) {
+
+	feedURL, _ := url.Parse("https://example.com/feed")
+	var feed RSSFeed
+	feed.Channel.Link = "https://example.com/blog/"
+	base := feedBase(&feed, feedURL)
+
+	if got, want := canonicalURL("2024/05/post", base), "https://example.com/blog/2024/05/post"; got != want {
+		t.Errorf("item link: got %q, want %q", got, want)
+	}
+	content := `<a href="2024/05/post">post</a>`
+	want := `<a href="https://example.com/blog/2024/05/post">post</a>`
+	if got := resolveContentLinks(content, base); got != want {
+		t.Errorf("content: got %q, want %q", got, want)
+	}
+}
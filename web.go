@@ -0,0 +1,571 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colfarl/gator/internal/database"
+	"github.com/google/uuid"
+)
+
+// The web reader is rendered on the server from the templates and stylesheet
+// in web/, compiled into the binary so it needs nothing but the database
+
+//go:embed web/templates/*.html web/static
+var webFiles embed.FS
+
+const (
+	webTokenCookie = "gator_token"
+	webPageSize    = 25
+	webTextWidth   = 90
+)
+
+type webHandler func(w http.ResponseWriter, r *http.Request, user database.User)
+
+type webServer struct {
+	s     *state
+	pages map[string]*template.Template
+}
+
+// webPage holds what layout.html needs, each page embeds it
+type webPage struct {
+	Title  string
+	User   string
+	Search string
+	Error  string
+}
+
+type webSource struct {
+	Label   string
+	Href    string
+	Current bool
+	Indent  bool
+}
+
+type webPost struct {
+	ID          string
+	Title       string
+	Url         string
+	FeedTitle   string
+	FeedHref    string
+	PublishedAt time.Time
+	Text        string
+	Read        bool
+	Starred     bool
+	Highlighted bool
+}
+
+type timelinePage struct {
+	webPage
+	Sources []webSource
+	Heading string
+	Posts   []webPost
+	Return  string
+	Prev    string
+	Next    string
+}
+
+type feedsPage struct {
+	webPage
+	Follows []followRecord
+	Tags    map[uuid.UUID][]string
+	Name    string
+	Url     string
+}
+
+var webFuncs = template.FuncMap{
+	"date": func(t time.Time) string {
+		return t.Local().Format("2 Jan 2006 15:04")
+	},
+	"deref": stringValue,
+}
+
+func registerWebRoutes(mux *http.ServeMux, s *state) error {
+
+	pages := map[string]*template.Template{}
+	for _, name := range []string{"timeline", "feeds", "login"} {
+		t, err := template.New(name).Funcs(webFuncs).ParseFS(webFiles, "web/templates/layout.html", "web/templates/"+name+".html")
+		if err != nil {
+			return err
+		}
+		pages[name] = t
+	}
+
+	static, err := fs.Sub(webFiles, "web/static")
+	if err != nil {
+		return err
+	}
+
+	ws := &webServer{s: s, pages: pages}
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /login", ws.handleLoginPage)
+	mux.HandleFunc("POST /login", ws.handleLogin)
+	mux.HandleFunc("POST /logout", ws.handleLogout)
+	mux.HandleFunc("GET /{$}", ws.loggedIn(ws.handleTimeline))
+	mux.HandleFunc("GET /feeds", ws.loggedIn(ws.handleFeeds))
+	mux.HandleFunc("POST /feeds", ws.loggedIn(ws.handleAddFeed))
+	mux.HandleFunc("POST /feeds/{id}/unfollow", ws.loggedIn(ws.handleUnfollow))
+	mux.HandleFunc("POST /posts/{id}/open", ws.loggedIn(ws.handleOpen))
+	mux.HandleFunc("POST /posts/{id}/read", ws.loggedIn(ws.handleSetRead(true)))
+	mux.HandleFunc("POST /posts/{id}/unread", ws.loggedIn(ws.handleSetRead(false)))
+	mux.HandleFunc("POST /posts/{id}/star", ws.loggedIn(ws.handleSetStarred(true)))
+	mux.HandleFunc("POST /posts/{id}/unstar", ws.loggedIn(ws.handleSetStarred(false)))
+	return nil
+}
+
+// ======== Helpers ========
+
+func (ws *webServer) render(w http.ResponseWriter, status int, name string, data any) {
+	var b strings.Builder
+	err := ws.pages[name].ExecuteTemplate(&b, "layout", data)
+	if err != nil {
+		fmt.Println("web error:", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprint(w, b.String())
+}
+
+func (ws *webServer) fail(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	fmt.Println("web error:", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+// loggedIn reads the API token the login form stored in a cookie, anyone
+// without a valid one is sent to /login
+func (ws *webServer) loggedIn(handler webHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		cookie, err := r.Cookie(webTokenCookie)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		user, err := userForAPIToken(ws.s, cookie.Value)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.SetCookie(w, &http.Cookie{Name: webTokenCookie, Path: "/", MaxAge: -1})
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			ws.fail(w, err)
+			return
+		}
+
+		handler(w, r, user)
+	}
+}
+
+// returnTo is where a form goes back to once it is done, only paths on this
+// server are allowed
+func returnTo(r *http.Request) string {
+	v := r.FormValue("return")
+	if !strings.HasPrefix(v, "/") || strings.HasPrefix(v, "//") || strings.HasPrefix(v, "/\\") {
+		return "/"
+	}
+	return v
+}
+
+func timelineHref(q url.Values) string {
+	if len(q) == 0 {
+		return "/"
+	}
+	return "/?" + q.Encode()
+}
+
+func newWebPost(p database.GetPostsForUserRow) webPost {
+	return webPost{
+		ID: p.ID.String(),
+		Title: p.Title,
+		Url: p.Url,
+		FeedTitle: p.FeedTitle,
+		FeedHref: timelineHref(url.Values{"feed": {p.FeedID.String()}}),
+		PublishedAt: p.PublishedAt,
+		Text: renderHTML(p.Description.String, webTextWidth, false),
+		Read: p.ReadAt.Valid,
+		Starred: p.StarredAt.Valid,
+		Highlighted: p.Highlighted,
+	}
+}
+
+// ======== Login ========
+
+func (ws *webServer) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	ws.render(w, http.StatusOK, "login", webPage{Title: "Log in"})
+}
+
+// handleLogin checks the password and hands the browser a token of its own,
+// so logging out here revokes it without touching the CLI session
+func (ws *webServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	user, err := logInWithPassword(ws.s, name, r.FormValue("password"))
+	if errors.Is(err, errWrongPassword) {
+		ws.render(w, http.StatusUnauthorized, "login", webPage{Title: "Log in", Error: "Wrong user name or password."})
+		return
+	}
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+
+	token, _, err := createAPIToken(ws.s, user, "web", false)
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name: webTokenCookie,
+		Value: token,
+		Path: "/",
+		MaxAge: 60 * 60 * 24 * 365,
+		HttpOnly: true,
+		Secure: r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (ws *webServer) handleLogout(w http.ResponseWriter, r *http.Request) {
+
+	cookie, err := r.Cookie(webTokenCookie)
+	if err == nil && cookie.Value != "" {
+		err = ws.s.db.DeleteAPITokenByHash(r.Context(), hashAPIToken(cookie.Value))
+		if err != nil {
+			ws.fail(w, err)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: webTokenCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// ======== Timeline ========
+
+// sources lists the sidebar: the fixed views, then tags, then followed feeds
+func (ws *webServer) sources(r *http.Request, user database.User) ([]webSource, string, error) {
+
+	q := r.URL.Query()
+	view, feed, tag := q.Get("view"), q.Get("feed"), q.Get("tag")
+	heading := "All posts"
+
+	sources := []webSource{
+		{Label: "All", Href: "/", Current: view == "" && feed == "" && tag == ""},
+		{Label: "Unread", Href: timelineHref(url.Values{"view": {"unread"}}), Current: view == "unread" && feed == "" && tag == ""},
+		{Label: "Starred", Href: timelineHref(url.Values{"view": {"starred"}}), Current: view == "starred" && feed == "" && tag == ""},
+	}
+	switch view {
+	case "unread":
+		heading = "Unread"
+	case "starred":
+		heading = "Starred"
+	}
+
+	tags, err := ws.s.db.GetFeedTagsForUser(r.Context(), user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	var names []string
+	for _, v := range tags {
+		names = append(names, v.Name)
+	}
+	for _, v := range uniqueTags(names) {
+		sources = append(sources, webSource{Label: "#" + v, Href: timelineHref(url.Values{"tag": {v}}), Current: tag == v})
+		if tag == v {
+			heading = "#" + v
+		}
+	}
+
+	follows, err := ws.s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, v := range follows {
+		id := v.FeedID.String()
+		sources = append(sources, webSource{Label: v.FeedTitle, Href: timelineHref(url.Values{"feed": {id}}), Current: feed == id, Indent: true})
+		if feed == id {
+			heading = v.FeedTitle
+		}
+	}
+	return sources, heading, nil
+}
+
+func (ws *webServer) handleTimeline(w http.ResponseWriter, r *http.Request, user database.User) {
+
+	q := r.URL.Query()
+	params := database.GetPostsForUserParams{
+		UserID: user.ID,
+		Limit: webPageSize + 1,
+	}
+
+	switch q.Get("view") {
+	case "unread":
+		params.Read = sql.NullBool{Bool: false, Valid: true}
+	case "starred":
+		params.Starred = sql.NullBool{Bool: true, Valid: true}
+	}
+	if v := q.Get("feed"); v != "" {
+		feedID, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "feed must be a uuid", http.StatusBadRequest)
+			return
+		}
+		params.FeedID = uuid.NullUUID{UUID: feedID, Valid: true}
+	}
+	if v := q.Get("tag"); v != "" {
+		params.Tag = sql.NullString{String: v, Valid: true}
+	}
+	search := strings.TrimSpace(q.Get("q"))
+	if search != "" {
+		params.Search = sql.NullString{String: search, Valid: true}
+	}
+	offset, err := strconv.Atoi(q.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	params.Offset = int32(offset)
+
+	posts, err := ws.s.db.GetPostsForUser(r.Context(), params)
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+
+	sources, heading, err := ws.sources(r, user)
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+	if search != "" {
+		heading = fmt.Sprintf("%s matching “%s”", heading, search)
+	}
+
+	page := timelinePage{
+		webPage: webPage{Title: heading, User: user.Name.String, Search: search},
+		Sources: sources,
+		Heading: heading,
+		Return: r.URL.RequestURI(),
+	}
+
+	if len(posts) > webPageSize {
+		posts = posts[:webPageSize]
+		q.Set("offset", strconv.Itoa(offset+webPageSize))
+		page.Next = timelineHref(q)
+	}
+	if offset > 0 {
+		q.Set("offset", strconv.Itoa(max(offset-webPageSize, 0)))
+		if offset <= webPageSize {
+			q.Del("offset")
+		}
+		page.Prev = timelineHref(q)
+	}
+	for _, v := range posts {
+		page.Posts = append(page.Posts, newWebPost(v))
+	}
+
+	ws.render(w, http.StatusOK, "timeline", page)
+}
+
+func (ws *webServer) findPost(w http.ResponseWriter, r *http.Request, user database.User) (database.GetPostsForUserRow, bool) {
+
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "post id must be a uuid", http.StatusBadRequest)
+		return database.GetPostsForUserRow{}, false
+	}
+
+	post, err := findPost(ws.s, user, id.String())
+	if errors.Is(err, errPostNotFound) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return database.GetPostsForUserRow{}, false
+	}
+	if err != nil {
+		ws.fail(w, err)
+		return database.GetPostsForUserRow{}, false
+	}
+	return post, true
+}
+
+// handleOpen marks the post read on the way to it, like open does
+func (ws *webServer) handleOpen(w http.ResponseWriter, r *http.Request, user database.User) {
+
+	post, ok := ws.findPost(w, r, user)
+	if !ok {
+		return
+	}
+
+	err := ws.s.db.SetPostRead(r.Context(), database.SetPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+		ReadAt: sql.NullTime{Time: time.Now(), Valid: true},
+	})
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+	http.Redirect(w, r, post.Url, http.StatusSeeOther)
+}
+
+func (ws *webServer) handleSetRead(read bool) webHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		post, ok := ws.findPost(w, r, user)
+		if !ok {
+			return
+		}
+
+		params := database.SetPostReadParams{
+			UserID: user.ID,
+			PostID: post.ID,
+		}
+		if read {
+			params.ReadAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+
+		err := ws.s.db.SetPostRead(r.Context(), params)
+		if err != nil {
+			ws.fail(w, err)
+			return
+		}
+		http.Redirect(w, r, returnTo(r), http.StatusSeeOther)
+	}
+}
+
+func (ws *webServer) handleSetStarred(starred bool) webHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+
+		post, ok := ws.findPost(w, r, user)
+		if !ok {
+			return
+		}
+
+		params := database.SetPostStarredParams{
+			UserID: user.ID,
+			PostID: post.ID,
+		}
+		if starred {
+			params.StarredAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+
+		err := ws.s.db.SetPostStarred(r.Context(), params)
+		if err != nil {
+			ws.fail(w, err)
+			return
+		}
+		http.Redirect(w, r, returnTo(r), http.StatusSeeOther)
+	}
+}
+
+// ======== Feeds ========
+
+func (ws *webServer) feedsPage(r *http.Request, user database.User) (feedsPage, error) {
+
+	page := feedsPage{
+		webPage: webPage{Title: "Feeds", User: user.Name.String},
+		Tags: map[uuid.UUID][]string{},
+	}
+
+	follows, err := ws.s.db.GetFeedFollowsForUser(r.Context(), user.ID)
+	if err != nil {
+		return page, err
+	}
+	for _, v := range follows {
+		page.Follows = append(page.Follows, newFollowRecord(v))
+	}
+
+	tags, err := ws.s.db.GetFeedTagsForUser(r.Context(), user.ID)
+	if err != nil {
+		return page, err
+	}
+	for _, v := range tags {
+		page.Tags[v.FeedID] = append(page.Tags[v.FeedID], v.Name)
+	}
+	return page, nil
+}
+
+func (ws *webServer) handleFeeds(w http.ResponseWriter, r *http.Request, user database.User) {
+
+	page, err := ws.feedsPage(r, user)
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+	ws.render(w, http.StatusOK, "feeds", page)
+}
+
+// handleAddFeed follows the url when gator already knows the feed and adds
+// it otherwise, which is when the name is needed
+func (ws *webServer) handleAddFeed(w http.ResponseWriter, r *http.Request, user database.User) {
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	feedURL := strings.TrimSpace(r.FormValue("url"))
+
+	showError := func(msg string) {
+		page, err := ws.feedsPage(r, user)
+		if err != nil {
+			ws.fail(w, err)
+			return
+		}
+		page.Error, page.Name, page.Url = msg, name, feedURL
+		ws.render(w, http.StatusBadRequest, "feeds", page)
+	}
+
+	parsed, err := url.Parse(feedURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		showError("Enter the http(s) url of the feed.")
+		return
+	}
+
+	feed, err := lookupFeed(r.Context(), ws.s.db, feedURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		if name == "" {
+			showError("gator does not know this feed yet, give it a name.")
+			return
+		}
+		_, err = addFeed(ws.s, user, name, feedURL)
+	} else if err == nil {
+		_, err = followFeed(ws.s.db, user, feed.ID)
+	}
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+	http.Redirect(w, r, "/feeds", http.StatusSeeOther)
+}
+
+func (ws *webServer) handleUnfollow(w http.ResponseWriter, r *http.Request, user database.User) {
+
+	feedID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "feed id must be a uuid", http.StatusBadRequest)
+		return
+	}
+
+	err = ws.s.db.DeleteFeedFollow(r.Context(), database.DeleteFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		ws.fail(w, err)
+		return
+	}
+	http.Redirect(w, r, "/feeds", http.StatusSeeOther)
+}